@@ -0,0 +1,338 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+// == CLASS ==================================================================
+
+// FS is a read-only io/fs view of the store. It implements fs.FS,
+// fs.ReadDirFS, fs.StatFS and fs.ReadFileFS, so a store can be passed to
+// html/template.ParseFS, http.FS, fs.WalkDir and the like.
+//
+// Names follow the io/fs conventions: they are unrooted and slash
+// separated, "." being the root directory of the store.
+type FS struct {
+	store StoreInterface
+}
+
+var _ fs.FS = (*FS)(nil)         // verify it extends the fs.FS interface
+var _ fs.ReadDirFS = (*FS)(nil)  // verify it extends the fs.ReadDirFS interface
+var _ fs.StatFS = (*FS)(nil)     // verify it extends the fs.StatFS interface
+var _ fs.ReadFileFS = (*FS)(nil) // verify it extends the fs.ReadFileFS interface
+
+// fsMetadataColumns are the columns needed to describe a record,
+// i.e. everything except the contents
+var fsMetadataColumns = []string{
+	COLUMN_ID,
+	COLUMN_PARENT_ID,
+	COLUMN_TYPE,
+	COLUMN_NAME,
+	COLUMN_SIZE,
+	COLUMN_EXTENSION,
+	COLUMN_PATH,
+	COLUMN_HASH,
+	COLUMN_CREATED_AT,
+	COLUMN_UPDATED_AT,
+	COLUMN_DELETED_AT,
+}
+
+// == CONSTRUCTORS ===========================================================
+
+// FS returns a read-only io/fs view of the store
+func (store *Store) FS() *FS {
+	return &FS{store: store}
+}
+
+// == INTERFACE IMPLEMENTATION ===============================================
+
+// Open opens the named file or directory
+func (fsys *FS) Open(name string) (fs.File, error) {
+	record, err := fsys.find("open", name, fsMetadataColumns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := newFileInfo(name, record)
+
+	if record.IsDirectory() {
+		return &fsDirectory{fsys: fsys, name: name, info: info}, nil
+	}
+
+	reader, err := fsys.store.Open(record.Path())
+
+	if err != nil {
+		return nil, fsPathError("open", name, err)
+	}
+
+	return &fsFile{info: info, reader: reader}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	record, err := fsys.find("readdir", name, fsMetadataColumns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !record.IsDirectory() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries, err := fsys.children(name, record)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+// ReadFile reads the named file and returns its contents
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	record, err := fsys.find("readfile", name, fsMetadataColumns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if record.IsDirectory() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+
+	contents, err := fsys.store.ReadFile(record.Path())
+
+	if err != nil {
+		return nil, fsPathError("readfile", name, err)
+	}
+
+	return contents, nil
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	record, err := fsys.find("stat", name, fsMetadataColumns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(name, record), nil
+}
+
+// == PRIVATE METHODS ========================================================
+
+// children returns the directory entries of the directory record, sorted by name
+func (fsys *FS) children(name string, directory *Record) ([]fs.DirEntry, error) {
+	records, err := fsys.store.RecordList(RecordQueryOptions{
+		ParentID: directory.ID(),
+		Columns:  fsMetadataColumns,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]fs.DirEntry, 0, len(records))
+
+	for i := range records {
+		entries = append(entries, fs.FileInfoToDirEntry(newFileInfo(path.Join(name, records[i].Name()), &records[i])))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// find looks up the record for the io/fs name, returning
+// a *fs.PathError if the name is invalid or does not exist
+func (fsys *FS) find(op string, name string, columns []string) (*Record, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	storePath := ROOT_PATH
+	if name != "." {
+		storePath = ROOT_PATH + name
+	}
+
+	record, err := fsys.store.RecordFindByPath(storePath, RecordQueryOptions{Columns: columns})
+
+	if errors.Is(err, ErrNotFound) {
+		record, err = nil, nil // the store is in strict mode
+	}
+
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if record == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return record, nil
+}
+
+// fsPathError returns the error for the io/fs name,
+// unwrapping the *fs.PathError returned by the store
+func fsPathError(op string, name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// == FILE ===================================================================
+
+// fsFile is an open file, its contents are streamed from the store
+type fsFile struct {
+	info   *fileInfo
+	reader io.ReadSeekCloser
+}
+
+var _ fs.File = (*fsFile)(nil)
+var _ io.ReadSeeker = (*fsFile)(nil)
+
+func (file *fsFile) Close() error {
+	return file.reader.Close()
+}
+
+func (file *fsFile) Read(p []byte) (int, error) {
+	return file.reader.Read(p)
+}
+
+func (file *fsFile) Seek(offset int64, whence int) (int64, error) {
+	return file.reader.Seek(offset, whence)
+}
+
+func (file *fsFile) Stat() (fs.FileInfo, error) {
+	return file.info, nil
+}
+
+// == DIRECTORY ==============================================================
+
+// fsDirectory is an open directory, its entries are loaded on first ReadDir
+type fsDirectory struct {
+	fsys    *FS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*fsDirectory)(nil)
+
+func (dir *fsDirectory) Close() error {
+	return nil
+}
+
+func (dir *fsDirectory) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir reads the directory entries, following the fs.ReadDirFile contract:
+// n > 0 returns at most n entries and io.EOF at the end, n <= 0 returns all
+// the remaining entries
+func (dir *fsDirectory) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !dir.loaded {
+		entries, err := dir.fsys.children(dir.name, dir.info.record)
+
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: dir.name, Err: err}
+		}
+
+		dir.entries = entries
+		dir.loaded = true
+	}
+
+	remaining := dir.entries[dir.offset:]
+
+	if n <= 0 {
+		dir.offset = len(dir.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	dir.offset += n
+
+	return remaining[:n], nil
+}
+
+func (dir *fsDirectory) Stat() (fs.FileInfo, error) {
+	return dir.info, nil
+}
+
+// == FILE INFO ==============================================================
+
+// fileInfo is a fs.FileInfo backed by a Record
+type fileInfo struct {
+	name   string
+	record *Record
+}
+
+var _ fs.FileInfo = (*fileInfo)(nil)
+
+func newFileInfo(name string, record *Record) *fileInfo {
+	return &fileInfo{name: path.Base(name), record: record}
+}
+
+func (info *fileInfo) IsDir() bool {
+	return info.record.IsDirectory()
+}
+
+func (info *fileInfo) ModTime() time.Time {
+	return carbon.Parse(info.record.UpdatedAt(), carbon.UTC).StdTime()
+}
+
+func (info *fileInfo) Mode() fs.FileMode {
+	if info.record.IsDirectory() {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+func (info *fileInfo) Name() string {
+	return info.name
+}
+
+func (info *fileInfo) Size() int64 {
+	if info.record.IsDirectory() {
+		return 0
+	}
+
+	size, err := strconv.ParseInt(info.record.Size(), 10, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return size
+}
+
+// Sys returns the underlying *Record
+func (info *fileInfo) Sys() any {
+	return info.record
+}
@@ -0,0 +1,181 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gouniverse/utils"
+)
+
+func initFSStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	docs := NewDirectory().
+		SetParentID(ROOT_ID).
+		SetName("docs").
+		SetPath(ROOT_PATH + "docs")
+
+	empty := NewDirectory().
+		SetParentID(docs.ID()).
+		SetName("empty").
+		SetPath(docs.Path() + PATH_SEPARATOR + "empty")
+
+	files := []*Record{docs, empty}
+
+	for name, contents := range map[string]string{
+		"index.html": "<h1>Hello</h1>",
+		"readme.txt": "README",
+	} {
+		files = append(files, NewFile().
+			SetParentID(ROOT_ID).
+			SetName(name).
+			SetPath(ROOT_PATH+name).
+			SetSize(utils.ToString(len([]byte(contents)))).
+			SetExtension(path.Ext(name)[1:]).
+			SetContents(contents))
+	}
+
+	files = append(files, NewFile().
+		SetParentID(docs.ID()).
+		SetName("a.txt").
+		SetPath(docs.Path()+PATH_SEPARATOR+"a.txt").
+		SetSize(utils.ToString(len([]byte("AAA")))).
+		SetExtension("txt").
+		SetContents("AAA"))
+
+	for _, file := range files {
+		err = store.RecordCreate(file)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return store
+}
+
+func TestFSPassesFSTest(t *testing.T) {
+	store := initFSStore(t, "fs_test")
+
+	err := fstest.TestFS(store.FS(), "index.html", "readme.txt", "docs", "docs/a.txt", "docs/empty")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestFSReadFile(t *testing.T) {
+	store := initFSStore(t, "fs_read_file")
+
+	contents, err := fs.ReadFile(store.FS(), "docs/a.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "AAA" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	_, err = fs.ReadFile(store.FS(), "docs/missing.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+}
+
+func TestFSStat(t *testing.T) {
+	store := initFSStore(t, "fs_stat")
+
+	info, err := fs.Stat(store.FS(), "docs/a.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if info.Name() != "a.txt" {
+		t.Fatal("unexpected name:", info.Name())
+	}
+
+	if info.Size() != 3 {
+		t.Fatal("unexpected size:", info.Size())
+	}
+
+	if info.IsDir() {
+		t.Fatal("File MUST NOT be a directory")
+	}
+
+	if info.ModTime().IsZero() {
+		t.Fatal("ModTime MUST NOT be zero")
+	}
+
+	if _, ok := info.Sys().(*Record); !ok {
+		t.Fatal("Sys MUST return a *Record")
+	}
+
+	info, err = fs.Stat(store.FS(), "docs")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !info.IsDir() {
+		t.Fatal("Directory MUST be a directory")
+	}
+}
+
+func TestFSReadDir(t *testing.T) {
+	store := initFSStore(t, "fs_read_dir")
+
+	entries, err := fs.ReadDir(store.FS(), ".")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	expected := []string{"docs", "index.html", "readme.txt"}
+
+	if len(names) != len(expected) {
+		t.Fatal("unexpected entries:", names)
+	}
+
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatal("unexpected entries:", names)
+		}
+	}
+
+	_, err = fs.ReadDir(store.FS(), "readme.txt")
+
+	if err == nil {
+		t.Fatal("must return error as readme.txt is not a directory")
+	}
+}
+
+func TestFSOpenInvalidPath(t *testing.T) {
+	store := initFSStore(t, "fs_open_invalid")
+
+	_, err := store.FS().Open("/docs")
+
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("expected fs.ErrInvalid, found:", err)
+	}
+}
@@ -0,0 +1,1321 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+	"github.com/gouniverse/uid"
+	"github.com/samber/lo"
+)
+
+var _ StoreInterface = (*MemoryStore)(nil) // verify it extends the interface
+
+// == CLASS ==================================================================
+
+// MemoryStore is an in-memory implementation of StoreInterface, to be used
+// in unit tests instead of a database. The records are kept in maps keyed
+// by ID and by path, and the RecordQueryOptions filters, sorting,
+// pagination and soft delete behave as with the SQL backed Store.
+//
+// Transactions are emulated with snapshots: the records are restored
+// if the transaction function fails. The mutex is held for the whole
+// transaction, so that the other goroutines wait for it to end.
+type MemoryStore struct {
+	*memoryState
+	inTx bool // bound to a transaction, which holds the mutex
+
+	storeSettings
+}
+
+// memoryState is the state of a MemoryStore,
+// shared with its copies bound to a transaction
+type memoryState struct {
+	mutex    sync.Mutex
+	records  map[string]*memoryRecord // the records by ID
+	paths    map[string]string        // the IDs of the live records by path
+	names    map[string]string        // the IDs of the live records by parent ID and name
+	sequence int                      // the insertion counter, the default sort order
+}
+
+// memoryRecord is a record as kept by the MemoryStore
+type memoryRecord struct {
+	data     map[string]string
+	sequence int
+	versions []map[string]string // the versions of the contents, the oldest first
+}
+
+// == CONSTRUCTORS ===========================================================
+
+// NewMemoryStore creates a new in-memory store, with its root directory
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{
+		memoryState: &memoryState{
+			records: map[string]*memoryRecord{},
+			paths:   map[string]string{},
+			names:   map[string]string{},
+		},
+		storeSettings: storeSettings{
+			pathRules:    DefaultPathRules(),
+			cursorSecret: cursorSecretGenerate(),
+		},
+	}
+
+	// Creating the root directory of an empty store cannot fail
+	_ = store.AutoMigrate()
+
+	return store
+}
+
+// == PUBLIC METHODS =========================================================
+
+// AutoMigrate creates the root directory, if missing
+func (store *MemoryStore) AutoMigrate() error {
+	recordCount, err := store.RecordCount(RecordQueryOptions{
+		Path: ROOT_PATH,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if recordCount > 0 {
+		return nil
+	}
+
+	rootDir := NewDirectory().
+		SetID(ROOT_ID).
+		SetPath(ROOT_PATH).
+		SetName("root").
+		SetParentID("-1")
+
+	return store.RecordCreate(rootDir)
+}
+
+// ContentsMigrateToContentTable does nothing, as the MemoryStore has
+// no tables
+func (store *MemoryStore) ContentsMigrateToContentTable() error {
+	return nil
+}
+
+// ContentsMigrateToBinary does nothing, as the contents
+// of the in-memory store are binary safe
+func (store *MemoryStore) ContentsMigrateToBinary() error {
+	return nil
+}
+
+// MigrationsPending returns no migrations, as the in-memory store has no schema
+func (store *MemoryStore) MigrationsPending() ([]Migration, error) {
+	return []Migration{}, nil
+}
+
+// EnableAutoSuffix makes RecordCreate and Copy add the first free
+// suffix to a name which is taken ("report (1).pdf"), instead of
+// failing with ErrExists
+func (store *MemoryStore) EnableAutoSuffix(enabled bool) {
+	store.autoSuffixEnabled = enabled
+}
+
+// EnableDebug - enables the debug option
+func (store *MemoryStore) EnableDebug(debug bool) {
+	store.debugEnabled = debug
+}
+
+// EnableStrictMode - enables the strict mode, in which RecordFindByID
+// and RecordFindByPath return ErrNotFound instead of nil
+func (store *MemoryStore) EnableStrictMode(strict bool) {
+	store.strictModeEnabled = strict
+}
+
+// SetPathRules sets the rules the paths and names are validated against
+func (store *MemoryStore) SetPathRules(rules PathRules) {
+	store.pathRules = rules
+}
+
+// FS returns a read-only io/fs view of the store
+func (store *MemoryStore) FS() *FS {
+	return &FS{store: store}
+}
+
+// WithTx executes the function in an emulated transaction,
+// the records being restored if the function returns an error
+// or panics. Nested transactions join the outer one.
+func (store *MemoryStore) WithTx(ctx context.Context, fn func(tx *MemoryStore) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.transaction(func(txStore *MemoryStore) error {
+		return fn(txStore)
+	})
+}
+
+// Transaction executes the function in a transaction, like WithTx,
+// for callers which only know the store by its interface
+func (store *MemoryStore) Transaction(ctx context.Context, fn func(tx StoreInterface) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	return store.WithTx(ctx, func(tx *MemoryStore) error {
+		return fn(tx)
+	})
+}
+
+func (store *MemoryStore) RecordCreate(record *Record) error {
+	return store.recordCreate(record, store.autoSuffixEnabled)
+}
+
+// recordCreate inserts the record, with autoSuffix adding a suffix
+// to its name if it is taken instead of failing with ErrExists
+func (store *MemoryStore) recordCreate(record *Record, autoSuffix bool) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	record.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	record.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	hashOnWrite(record, record.Data(), "")
+
+	err := store.pathRules.validateData(record.Data())
+
+	if err != nil {
+		return err
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	if _, exists := store.records[record.ID()]; exists {
+		return wrapError(ErrExists, "record already exists: "+record.ID())
+	}
+
+	err = store.ensureUnique(record, autoSuffix)
+
+	if err != nil {
+		return err
+	}
+
+	store.sequence++
+	stored := &memoryRecord{
+		data:     copyData(record.Data()),
+		sequence: store.sequence,
+	}
+
+	store.records[record.ID()] = stored
+	store.indexAdd(stored)
+
+	// A new file without contents has no version until written
+	if store.versioningEnabled && record.IsFile() && record.Contents() != "" {
+		store.versionAppend(stored, VersionOptions{})
+	}
+
+	record.MarkAsNotDirty()
+
+	return nil
+}
+
+func (store *MemoryStore) RecordCount(options RecordQueryOptions) (int64, error) {
+	options.CountOnly = true
+
+	unlock := store.lock()
+	defer unlock()
+
+	return int64(len(store.query(options))), nil
+}
+
+func (store *MemoryStore) RecordDelete(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	return store.RecordDeleteByID(record.ID())
+}
+
+func (store *MemoryStore) RecordDeleteByID(id string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	subsCount := len(store.query(RecordQueryOptions{
+		ParentID:        id,
+		CountOnly:       true,
+		WithSoftDeleted: true,
+	}))
+
+	if subsCount > 0 {
+		return ErrNotEmpty
+	}
+
+	if stored, exists := store.records[id]; exists {
+		store.indexRemove(stored)
+		delete(store.records, id)
+	}
+
+	return nil
+}
+
+// RecordDeleteRecursive hard deletes the record and, for a directory,
+// everything it contains, soft deleted records included
+func (store *MemoryStore) RecordDeleteRecursive(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	if record.ID() == ROOT_ID {
+		return errors.New("root directory cannot be deleted")
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	subtree, err := store.subtree(record.ID())
+
+	if err != nil {
+		return err
+	}
+
+	for _, stored := range subtree {
+		store.indexRemove(stored)
+		delete(store.records, stored.data[COLUMN_ID])
+	}
+
+	return nil
+}
+
+// RecordFindByPath returns the live record with the path, or nil if there
+// is none. In strict mode ErrNotFound is returned instead of nil.
+func (store *MemoryStore) RecordFindByPath(path string, options RecordQueryOptions) (*Record, error) {
+	record, err := store.recordFindByPath(path, options)
+
+	if err == nil && record == nil && store.strictModeEnabled {
+		return nil, wrapError(ErrNotFound, "record not found: "+path)
+	}
+
+	return record, err
+}
+
+// RecordFindByID returns the record with the ID, or nil if there is none.
+// In strict mode ErrNotFound is returned instead of nil.
+func (store *MemoryStore) RecordFindByID(id string, options RecordQueryOptions) (*Record, error) {
+	record, err := store.recordFindByID(id, options)
+
+	if err == nil && record == nil && store.strictModeEnabled {
+		return nil, wrapError(ErrNotFound, "record not found: "+id)
+	}
+
+	return record, err
+}
+
+func (store *MemoryStore) RecordList(options RecordQueryOptions) ([]Record, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	list := []Record{}
+
+	for _, stored := range store.query(options) {
+		data := copyData(stored.data)
+
+		if len(options.Columns) > 0 {
+			data = lo.PickByKeys(data, options.Columns)
+		} else if !options.WithContents {
+			data = lo.PickByKeys(data, fsMetadataColumns)
+		}
+
+		list = append(list, *NewRecordFromExistingData(data))
+	}
+
+	return list, nil
+}
+
+// RecordLoadContents loads the contents of a record found or listed
+// without them. The record is not marked as changed.
+func (store *MemoryStore) RecordLoadContents(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[record.ID()]
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	record.contentsHydrate(stored.data[COLUMN_CONTENTS])
+
+	return nil
+}
+
+func (store *MemoryStore) RecordSoftDelete(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	record.SetDeletedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	return store.RecordUpdate(record)
+}
+
+func (store *MemoryStore) RecordSoftDeleteByID(id string) error {
+	record, err := store.recordFindByID(id, RecordQueryOptions{Columns: []string{"id", "deleted_at"}})
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return ErrNotFound
+	}
+
+	return store.RecordSoftDelete(record)
+}
+
+// RecordSoftDeleteRecursive soft deletes the record and, for a directory,
+// everything it contains, with the same deleted_at
+func (store *MemoryStore) RecordSoftDeleteRecursive(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	if record.ID() == ROOT_ID {
+		return errors.New("root directory cannot be deleted")
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	subtree, err := store.subtree(record.ID())
+
+	if err != nil {
+		return err
+	}
+
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+	for _, stored := range subtree {
+		if stored.data[COLUMN_DELETED_AT] == sb.NULL_DATETIME {
+			store.indexRemove(stored)
+			stored.data[COLUMN_DELETED_AT] = now
+			stored.data[COLUMN_UPDATED_AT] = now
+		}
+	}
+
+	record.SetDeletedAt(now)
+	record.SetUpdatedAt(now)
+	record.MarkAsNotDirty()
+
+	return nil
+}
+
+// RecordUpdate writes the changed fields of the record. A change of the
+// contents is kept as a new version of the file, when versioning is enabled.
+func (store *MemoryStore) RecordUpdate(record *Record) error {
+	return store.RecordUpdateVersioned(record, VersionOptions{})
+}
+
+// recordUpdate writes the changed fields of the record, a change of the
+// contents being kept as a new version with the options, if any. The hash
+// of changed contents is computed from them, unless given.
+func (store *MemoryStore) recordUpdate(record *Record, hash string, version *VersionOptions) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	record.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString())
+
+	hashOnWrite(record, record.DataChanged(), hash)
+
+	dataChanged := record.DataChanged()
+
+	delete(dataChanged, "id") // ID is not updateable
+
+	if len(dataChanged) < 1 {
+		return nil
+	}
+
+	err := store.pathRules.validateData(dataChanged)
+
+	if err != nil {
+		return err
+	}
+
+	unlock := store.lock()
+	defer unlock()
+
+	if stored, exists := store.records[record.ID()]; exists {
+		data := copyData(stored.data)
+
+		for key, value := range dataChanged {
+			data[key] = value
+		}
+
+		err := store.ensureUnique(NewRecordFromExistingData(data), false)
+
+		if err != nil {
+			return err
+		}
+
+		store.indexRemove(stored)
+		stored.data = data
+		store.indexAdd(stored)
+
+		if _, contentsChanged := dataChanged[COLUMN_CONTENTS]; contentsChanged && version != nil {
+			store.versionAppend(stored, *version)
+		}
+	}
+
+	record.MarkAsNotDirty()
+
+	return nil
+}
+
+// == PRIVATE METHODS ========================================================
+
+// recordCopy inserts a copy of the record with the ID, with its contents,
+// taking the ID, parent ID, name, extension and path of the copied record
+func (store *MemoryStore) recordCopy(id string, copied *Record) error {
+	unlock := store.lock()
+	defer unlock()
+
+	source, exists := store.records[id]
+
+	if !exists {
+		return wrapError(ErrNotFound, "record not found: "+id)
+	}
+
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+	data := copyData(source.data)
+	data[COLUMN_ID] = copied.ID()
+	data[COLUMN_PARENT_ID] = copied.ParentID()
+	data[COLUMN_NAME] = copied.Name()
+	data[COLUMN_EXTENSION] = copied.Extension()
+	data[COLUMN_PATH] = copied.Path()
+	data[COLUMN_CREATED_AT] = now
+	data[COLUMN_UPDATED_AT] = now
+	data[COLUMN_DELETED_AT] = sb.NULL_DATETIME
+
+	if _, exists := store.records[copied.ID()]; exists {
+		return wrapError(ErrExists, "record already exists: "+copied.ID())
+	}
+
+	err := store.ensureUnique(NewRecordFromExistingData(data), false)
+
+	if err != nil {
+		return err
+	}
+
+	store.sequence++
+	stored := &memoryRecord{
+		data:     data,
+		sequence: store.sequence,
+	}
+
+	store.records[copied.ID()] = stored
+	store.indexAdd(stored)
+
+	return nil
+}
+
+// recordEnsureUnique checks that no other live record has the path, or
+// the parent ID and name, of the record, see ensureUnique
+func (store *MemoryStore) recordEnsureUnique(record *Record, autoSuffix bool) error {
+	unlock := store.lock()
+	defer unlock()
+
+	return store.ensureUnique(record, autoSuffix)
+}
+
+// recordListAfter returns at most limit records matching the options,
+// sorted by the clause then by ID, after the position if not nil
+func (store *MemoryStore) recordListAfter(options RecordQueryOptions, clause OrderClause, position *cursorPosition, limit int) ([]Record, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	options.OrderBy = []OrderClause{clause, {Column: COLUMN_ID, SortOrder: clause.SortOrder}}
+
+	records := []Record{}
+
+	for _, stored := range store.query(options) {
+		if len(records) >= limit {
+			break
+		}
+
+		if position != nil && !memoryKeysetAfter(clause, *position, stored.data) {
+			continue
+		}
+
+		data := copyData(stored.data)
+
+		if len(options.Columns) > 0 {
+			data = lo.PickByKeys(data, options.Columns)
+		} else if !options.WithContents {
+			data = lo.PickByKeys(data, fsMetadataColumns)
+		}
+
+		records = append(records, *NewRecordFromExistingData(data))
+	}
+
+	return records, nil
+}
+
+// recordReader returns a reader for the contents of the file record
+func (store *MemoryStore) recordReader(record *Record) (io.ReadSeekCloser, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[record.ID()]
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return &contentsReader{Reader: bytes.NewReader([]byte(stored.data[COLUMN_CONTENTS]))}, nil
+}
+
+// recordWriter returns a writer buffering the contents of the (clean)
+// file path, which are written to the file when the writer is closed
+func (store *MemoryStore) recordWriter(filePath string) io.WriteCloser {
+	return &memoryWriter{store: store, filePath: filePath}
+}
+
+// descendantsList returns the live descendants of the directory matching
+// the options, in no particular order
+func (store *MemoryStore) descendantsList(directory *Record, options ListOptions) ([]Record, error) {
+	prefix := strings.TrimSuffix(directory.Path(), PATH_SEPARATOR) + PATH_SEPARATOR
+
+	records, err := store.RecordList(RecordQueryOptions{
+		PathStartsWith: prefix,
+		Columns:        fsMetadataColumns,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	descendants := []Record{}
+
+	for _, record := range records {
+		if record.ID() == directory.ID() || !strings.HasPrefix(record.Path(), prefix) {
+			continue
+		}
+
+		if options.MaxDepth > 0 && pathDepth(record.Path()) > pathDepth(directory.Path())+options.MaxDepth {
+			continue
+		}
+
+		if (options.FilesOnly && !record.IsFile()) || (options.DirsOnly && !record.IsDirectory()) {
+			continue
+		}
+
+		descendants = append(descendants, record)
+	}
+
+	return descendants, nil
+}
+
+// descendantsPathRewrite replaces the old path prefix of all the
+// descendants of a directory (soft deleted included) with the new one
+func (store *MemoryStore) descendantsPathRewrite(oldPath string, newPath string) error {
+	oldPrefix := strings.TrimSuffix(oldPath, PATH_SEPARATOR) + PATH_SEPARATOR
+	newPrefix := strings.TrimSuffix(newPath, PATH_SEPARATOR) + PATH_SEPARATOR
+
+	unlock := store.lock()
+	defer unlock()
+
+	for _, stored := range store.records {
+		if strings.HasPrefix(stored.data[COLUMN_PATH], oldPrefix) {
+			store.indexRemove(stored)
+			stored.data[COLUMN_PATH] = newPrefix + strings.TrimPrefix(stored.data[COLUMN_PATH], oldPrefix)
+			store.indexAdd(stored)
+		}
+	}
+
+	return nil
+}
+
+// descendantsRestore restores the descendants of the directory
+// which were soft deleted at the same time as the directory
+func (store *MemoryStore) descendantsRestore(directory *Record, deletedAt string) error {
+	unlock := store.lock()
+	defer unlock()
+
+	subtree, err := store.subtree(directory.ID())
+
+	if err != nil {
+		return err
+	}
+
+	for _, stored := range subtree {
+		if stored.data[COLUMN_ID] != directory.ID() && stored.data[COLUMN_DELETED_AT] == deletedAt {
+			stored.data[COLUMN_DELETED_AT] = sb.NULL_DATETIME
+			stored.data[COLUMN_UPDATED_AT] = carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+			store.indexAdd(stored)
+		}
+	}
+
+	return nil
+}
+
+// globList returns the live records, the in-memory store having
+// no cheaper way to find those which may match the pattern
+func (store *MemoryStore) globList(pattern string) ([]Record, error) {
+	return store.RecordList(RecordQueryOptions{Columns: fsMetadataColumns})
+}
+
+// chunksCopy does nothing, as the in-memory store keeps the streamed
+// contents with the record
+func (store *MemoryStore) chunksCopy(fromRecordID string, toRecordID string) error {
+	return nil
+}
+
+// versionContentsLoad does nothing, as the in-memory store keeps the
+// contents with the version
+func (store *MemoryStore) versionContentsLoad(version *Version) error {
+	return nil
+}
+
+// versionCreate keeps the current contents of the record with the ID as
+// its next version, and deletes the versions the retention does not keep
+func (store *MemoryStore) versionCreate(recordID string, options VersionOptions) error {
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[recordID]
+
+	if !exists {
+		return wrapError(ErrNotFound, "record not found: "+recordID)
+	}
+
+	store.versionAppend(stored, options)
+
+	return nil
+}
+
+// versionFind returns a copy of the version of the record with the ID
+// with its number, with its contents, or nil
+func (store *MemoryStore) versionFind(recordID string, number int) (*Version, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[recordID]
+
+	if !exists {
+		return nil, nil
+	}
+
+	for _, version := range stored.versions {
+		if version[COLUMN_VERSION] == strconv.Itoa(number) {
+			return NewVersionFromExistingData(copyData(version)), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// versionList returns the versions of the record with the ID, the latest
+// first, without their contents
+func (store *MemoryStore) versionList(recordID string) ([]Version, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[recordID]
+
+	if !exists {
+		return []Version{}, nil
+	}
+
+	return lo.Map(memoryVersionsLatestFirst(stored), func(version Version, _ int) Version {
+		return *NewVersionFromExistingData(lo.PickByKeys(version.Data(), versionMetadataColumns))
+	}), nil
+}
+
+// versionRecordIDs returns the IDs of the records which have versions
+func (store *MemoryStore) versionRecordIDs() ([]string, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	recordIDs := []string{}
+
+	for id, stored := range store.records {
+		if len(stored.versions) > 0 {
+			recordIDs = append(recordIDs, id)
+		}
+	}
+
+	return recordIDs, nil
+}
+
+// versionsPrune deletes the versions of the record with the ID which the
+// retention does not keep, returning the number of versions deleted
+func (store *MemoryStore) versionsPrune(recordID string) (int, error) {
+	unlock := store.lock()
+	defer unlock()
+
+	stored, exists := store.records[recordID]
+
+	if !exists {
+		return 0, nil
+	}
+
+	return store.versionsRetain(stored), nil
+}
+
+// versionAppend keeps the current contents of the record as its next
+// version, and deletes the versions the retention does not keep.
+// The mutex must be held by the caller.
+func (store *MemoryStore) versionAppend(stored *memoryRecord, options VersionOptions) {
+	last := 0
+
+	if len(stored.versions) > 0 {
+		last, _ = strconv.Atoi(stored.versions[len(stored.versions)-1][COLUMN_VERSION])
+	}
+
+	stored.versions = append(stored.versions, map[string]string{
+		COLUMN_ID:         uid.HumanUid(),
+		COLUMN_RECORD_ID:  stored.data[COLUMN_ID],
+		COLUMN_VERSION:    strconv.Itoa(last + 1),
+		COLUMN_SIZE:       stored.data[COLUMN_SIZE],
+		COLUMN_HASH:       stored.data[COLUMN_HASH],
+		COLUMN_CONTENTS:   stored.data[COLUMN_CONTENTS],
+		COLUMN_CONTENT_ID: "",
+		COLUMN_AUTHOR:     options.Author,
+		COLUMN_COMMENT:    options.Comment,
+		COLUMN_CREATED_AT: carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+	})
+
+	store.versionsRetain(stored)
+}
+
+// versionsRetain deletes the versions of the record which the retention
+// does not keep, returning the number of versions deleted.
+// The mutex must be held by the caller.
+func (store *MemoryStore) versionsRetain(stored *memoryRecord) int {
+	expired := versionsExpired(memoryVersionsLatestFirst(stored), store.versionRetention)
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	expiredIDs := lo.Map(expired, func(version Version, _ int) string {
+		return version.ID()
+	})
+
+	stored.versions = lo.Reject(stored.versions, func(version map[string]string, _ int) bool {
+		return lo.Contains(expiredIDs, version[COLUMN_ID])
+	})
+
+	return len(expired)
+}
+
+func (store *MemoryStore) recordFindByID(id string, options RecordQueryOptions) (*Record, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+
+	options.ID = id
+	options.Limit = 1
+
+	list, err := store.RecordList(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) > 0 {
+		return &list[0], nil
+	}
+
+	return nil, nil
+}
+
+func (store *MemoryStore) recordFindByPath(path string, options RecordQueryOptions) (*Record, error) {
+	if path == "" {
+		return nil, wrapError(ErrInvalidPath, "record path is empty")
+	}
+
+	path, err := store.pathRules.CleanPath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	options.Path = path
+	options.Limit = 1
+
+	list, err := store.RecordList(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) > 0 {
+		return &list[0], nil
+	}
+
+	return nil, nil
+}
+
+// query returns the records matching the options, sorted and paginated.
+// The mutex must be held by the caller.
+func (store *MemoryStore) query(options RecordQueryOptions) []*memoryRecord {
+	list := []*memoryRecord{}
+
+	for _, stored := range store.candidates(options) {
+		if memoryRecordMatches(stored.data, options) {
+			list = append(list, stored)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].sequence < list[j].sequence
+	})
+
+	if len(options.OrderBy) > 0 {
+		sort.SliceStable(list, func(i, j int) bool {
+			for _, clause := range options.OrderBy {
+				a, b := list[i].data, list[j].data
+
+				if !clause.IsAscending() {
+					a, b = b, a
+				}
+
+				if memoryValueLess(clause.Column, a, b) {
+					return true
+				}
+
+				if memoryValueLess(clause.Column, b, a) {
+					return false
+				}
+			}
+
+			return false
+		})
+	}
+
+	if options.CountOnly {
+		return list
+	}
+
+	if options.Offset > 0 {
+		if options.Offset >= len(list) {
+			return []*memoryRecord{}
+		}
+
+		list = list[options.Offset:]
+	}
+
+	if options.Limit > 0 && options.Limit < len(list) {
+		list = list[:options.Limit]
+	}
+
+	return list
+}
+
+// candidates returns the records which may match the options, using
+// the ID and path indexes when possible. The mutex must be held by the caller.
+func (store *MemoryStore) candidates(options RecordQueryOptions) map[string]*memoryRecord {
+	id := ""
+
+	switch {
+	case options.ID != "":
+		id = options.ID
+	case options.Path != "" && !options.WithSoftDeleted && !options.OnlySoftDeleted:
+		id = store.paths[options.Path]
+	default:
+		return store.records
+	}
+
+	stored, exists := store.records[id]
+
+	if !exists {
+		return map[string]*memoryRecord{}
+	}
+
+	return map[string]*memoryRecord{id: stored}
+}
+
+// subtree returns the record with the ID and, for a directory, all its
+// descendants (soft deleted included). The mutex must be held by the caller.
+func (store *MemoryStore) subtree(id string) ([]*memoryRecord, error) {
+	record, exists := store.records[id]
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	subtree := []*memoryRecord{record}
+
+	if record.data[COLUMN_TYPE] != TYPE_DIRECTORY {
+		return subtree, nil
+	}
+
+	prefix := strings.TrimSuffix(record.data[COLUMN_PATH], PATH_SEPARATOR) + PATH_SEPARATOR
+
+	for _, stored := range store.records {
+		if stored != record && strings.HasPrefix(stored.data[COLUMN_PATH], prefix) {
+			subtree = append(subtree, stored)
+		}
+	}
+
+	return subtree, nil
+}
+
+// lock locks the mutex, unless the store is bound to a transaction
+// which holds it already, and returns the function unlocking it
+func (store *MemoryStore) lock() func() {
+	if store.inTx {
+		return func() {}
+	}
+
+	store.mutex.Lock()
+
+	return store.mutex.Unlock
+}
+
+// indexAdd adds the record to the indexes, if it is live.
+// The mutex must be held by the caller.
+func (store *MemoryStore) indexAdd(stored *memoryRecord) {
+	if stored.data[COLUMN_DELETED_AT] != sb.NULL_DATETIME {
+		return
+	}
+
+	store.paths[stored.data[COLUMN_PATH]] = stored.data[COLUMN_ID]
+	store.names[memoryNameKey(stored.data[COLUMN_PARENT_ID], stored.data[COLUMN_NAME])] = stored.data[COLUMN_ID]
+}
+
+// indexRemove removes the record from the indexes.
+// The mutex must be held by the caller.
+func (store *MemoryStore) indexRemove(stored *memoryRecord) {
+	id := stored.data[COLUMN_ID]
+	nameKey := memoryNameKey(stored.data[COLUMN_PARENT_ID], stored.data[COLUMN_NAME])
+
+	if store.paths[stored.data[COLUMN_PATH]] == id {
+		delete(store.paths, stored.data[COLUMN_PATH])
+	}
+
+	if store.names[nameKey] == id {
+		delete(store.names, nameKey)
+	}
+}
+
+// reindex rebuilds the indexes of the live records, after the records
+// are restored by a rollback. The mutex must be held by the caller.
+func (store *MemoryStore) reindex() {
+	store.paths = map[string]string{}
+	store.names = map[string]string{}
+
+	for _, stored := range store.records {
+		store.indexAdd(stored)
+	}
+}
+
+// transaction executes the function with a copy of the store bound to
+// the transaction, holding the mutex until it returns, and restores the
+// records if it returns an error or panics. A store already bound to
+// a transaction joins it.
+func (store *MemoryStore) transaction(fn func(txStore *MemoryStore) error) (err error) {
+	if store.inTx {
+		return fn(store)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshot := store.snapshot()
+
+	defer func() {
+		r := recover()
+
+		if r != nil || err != nil {
+			store.records = snapshot
+			store.reindex()
+		}
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	txStore := *store
+	txStore.inTx = true
+
+	return fn(&txStore)
+}
+
+// transactionRun executes the function in an emulated transaction,
+// see transaction
+func (store *MemoryStore) transactionRun(fn func(txStorage recordStorage) error) error {
+	return store.transaction(func(txStore *MemoryStore) error {
+		return fn(txStore)
+	})
+}
+
+// inTransaction returns whether the store is bound to a transaction
+func (store *MemoryStore) inTransaction() bool {
+	return store.inTx
+}
+
+// snapshot returns a deep copy of the records.
+// The mutex must be held by the caller.
+func (store *MemoryStore) snapshot() map[string]*memoryRecord {
+	snapshot := make(map[string]*memoryRecord, len(store.records))
+
+	for id, stored := range store.records {
+		snapshot[id] = &memoryRecord{
+			data:     copyData(stored.data),
+			sequence: stored.sequence,
+			versions: lo.Map(stored.versions, func(version map[string]string, _ int) map[string]string {
+				return copyData(version)
+			}),
+		}
+	}
+
+	return snapshot
+}
+
+// ensureUnique checks that no other live record has the path, or the
+// parent ID and name, of the record. With autoSuffix the record is given
+// the first free suffixed name instead of failing.
+// The mutex must be held by the caller.
+func (store *MemoryStore) ensureUnique(record *Record, autoSuffix bool) error {
+	if record.DeletedAt() != sb.NULL_DATETIME {
+		return nil // soft deleted records do not take the path
+	}
+
+	if !store.conflicts(record.ID(), record.ParentID(), record.Name(), record.Path()) {
+		return nil
+	}
+
+	if !autoSuffix {
+		return wrapError(ErrExists, "record already exists at path: "+record.Path())
+	}
+
+	return suffixedNameSet(record, store.pathRules, func(name string, recordPath string) (bool, error) {
+		return store.conflicts(record.ID(), record.ParentID(), name, recordPath), nil
+	})
+}
+
+// conflicts returns whether a live record, other than the record with the
+// ID, has the path or the parent ID and name.
+// The mutex must be held by the caller.
+func (store *MemoryStore) conflicts(id string, parentID string, name string, recordPath string) bool {
+	if existingID, exists := store.paths[recordPath]; exists && existingID != id {
+		return true
+	}
+
+	if existingID, exists := store.names[memoryNameKey(parentID, name)]; exists && existingID != id {
+		return true
+	}
+
+	return false
+}
+
+// memoryNameKey returns the key of the names index for the parent ID and name
+func memoryNameKey(parentID string, name string) string {
+	return parentID + PATH_SEPARATOR + name
+}
+
+// memoryRecordMatches checks the record data against the query options,
+// the same way as the WHERE clause built by the SQL store
+func memoryRecordMatches(data map[string]string, options RecordQueryOptions) bool {
+	if options.ID != "" && data[COLUMN_ID] != options.ID {
+		return false
+	}
+
+	if len(options.IDIn) > 0 && !lo.Contains(options.IDIn, data[COLUMN_ID]) {
+		return false
+	}
+
+	if options.ParentID != "" && data[COLUMN_PARENT_ID] != options.ParentID {
+		return false
+	}
+
+	if len(options.ParentIDIn) > 0 && !lo.Contains(options.ParentIDIn, data[COLUMN_PARENT_ID]) {
+		return false
+	}
+
+	if options.NameLike != "" && !likeMatch(options.NameLike, data[COLUMN_NAME]) {
+		return false
+	}
+
+	if len(options.NameIn) > 0 && !lo.Contains(options.NameIn, data[COLUMN_NAME]) {
+		return false
+	}
+
+	if options.Extension != "" && data[COLUMN_EXTENSION] != options.Extension {
+		return false
+	}
+
+	if len(options.ExtensionIn) > 0 && !lo.Contains(options.ExtensionIn, data[COLUMN_EXTENSION]) {
+		return false
+	}
+
+	if options.SizeGreaterThan != "" && !memoryValueLess(COLUMN_SIZE, map[string]string{COLUMN_SIZE: options.SizeGreaterThan}, data) {
+		return false
+	}
+
+	if options.SizeLessThan != "" && !memoryValueLess(COLUMN_SIZE, data, map[string]string{COLUMN_SIZE: options.SizeLessThan}) {
+		return false
+	}
+
+	if options.CreatedAtGreaterThan != "" && data[COLUMN_CREATED_AT] <= options.CreatedAtGreaterThan {
+		return false
+	}
+
+	if options.CreatedAtLessThan != "" && data[COLUMN_CREATED_AT] >= options.CreatedAtLessThan {
+		return false
+	}
+
+	if options.UpdatedAtGreaterThan != "" && data[COLUMN_UPDATED_AT] <= options.UpdatedAtGreaterThan {
+		return false
+	}
+
+	if options.UpdatedAtLessThan != "" && data[COLUMN_UPDATED_AT] >= options.UpdatedAtLessThan {
+		return false
+	}
+
+	if options.DeletedAtGreaterThan != "" && data[COLUMN_DELETED_AT] <= options.DeletedAtGreaterThan {
+		return false
+	}
+
+	if options.DeletedAtLessThan != "" && data[COLUMN_DELETED_AT] >= options.DeletedAtLessThan {
+		return false
+	}
+
+	if options.Type != "" && data[COLUMN_TYPE] != options.Type {
+		return false
+	}
+
+	if options.Path != "" && data[COLUMN_PATH] != options.Path {
+		return false
+	}
+
+	if len(options.PathIn) > 0 && !lo.Contains(options.PathIn, data[COLUMN_PATH]) {
+		return false
+	}
+
+	if options.PathStartsWith != "" && !strings.HasPrefix(data[COLUMN_PATH], options.PathStartsWith) {
+		return false
+	}
+
+	if options.OnlySoftDeleted && data[COLUMN_DELETED_AT] == sb.NULL_DATETIME {
+		return false
+	}
+
+	if !options.WithSoftDeleted && !options.OnlySoftDeleted && data[COLUMN_DELETED_AT] != sb.NULL_DATETIME {
+		return false
+	}
+
+	return true
+}
+
+// memoryValueLess compares the column of two records,
+// the size being compared as a number
+func memoryValueLess(column string, a map[string]string, b map[string]string) bool {
+	if column == COLUMN_SIZE {
+		sizeA, _ := strconv.ParseInt(a[column], 10, 64)
+		sizeB, _ := strconv.ParseInt(b[column], 10, 64)
+		return sizeA < sizeB
+	}
+
+	return a[column] < b[column]
+}
+
+// copyData returns a copy of the record data
+func copyData(data map[string]string) map[string]string {
+	copied := make(map[string]string, len(data))
+
+	for key, value := range data {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// memoryKeysetAfter returns whether the record data is after the position,
+// in the order of the clause, the same way as keysetCondition
+func memoryKeysetAfter(clause OrderClause, position cursorPosition, data map[string]string) bool {
+	positionData := map[string]string{
+		clause.Column: position.Value,
+		COLUMN_ID:     position.ID,
+	}
+
+	a, b := positionData, data
+
+	if !clause.IsAscending() {
+		a, b = b, a
+	}
+
+	if memoryValueLess(clause.Column, a, b) {
+		return true
+	}
+
+	if memoryValueLess(clause.Column, b, a) {
+		return false
+	}
+
+	return a[COLUMN_ID] < b[COLUMN_ID]
+}
+
+// memoryVersionsLatestFirst returns the versions of the record,
+// the latest first, sharing their data with the record
+func memoryVersionsLatestFirst(stored *memoryRecord) []Version {
+	versions := make([]Version, 0, len(stored.versions))
+
+	for i := len(stored.versions) - 1; i >= 0; i-- {
+		versions = append(versions, *NewVersionFromExistingData(stored.versions[i]))
+	}
+
+	return versions
+}
+
+// == WRITER =================================================================
+
+// memoryWriter buffers the written contents,
+// and writes them to the file when closed
+type memoryWriter struct {
+	store    *MemoryStore
+	filePath string
+	buffer   bytes.Buffer
+	closed   bool
+}
+
+var _ io.WriteCloser = (*memoryWriter)(nil)
+
+func (writer *memoryWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, fs.ErrClosed
+	}
+
+	return writer.buffer.Write(p)
+}
+
+func (writer *memoryWriter) Close() error {
+	if writer.closed {
+		return fs.ErrClosed
+	}
+
+	writer.closed = true
+
+	return writer.store.WriteFile(writer.filePath, writer.buffer.Bytes())
+}
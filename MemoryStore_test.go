@@ -0,0 +1,334 @@
+package sqlfilestore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gouniverse/sb"
+)
+
+func initMemoryStore(t *testing.T) *MemoryStore {
+	store := NewMemoryStore()
+
+	err := store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for filePath, contents := range map[string]string{
+		"/docs/readme.txt":      "README",
+		"/docs/2026/report.txt": "REPORT 2026",
+		"/notes.md":             "NOTES",
+	} {
+		err = store.WriteFile(filePath, []byte(contents))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return store
+}
+
+func TestMemoryStoreRootCreated(t *testing.T) {
+	store := NewMemoryStore()
+
+	root, err := store.RecordFindByPath(ROOT_PATH, RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if root == nil || root.ID() != ROOT_ID || !root.IsDirectory() {
+		t.Fatal("Root directory MUST be created")
+	}
+}
+
+func TestMemoryStoreRecordList(t *testing.T) {
+	store := initMemoryStore(t)
+
+	files, err := store.RecordList(RecordQueryOptions{
+		Type:    TYPE_FILE,
+		OrderBy: []OrderClause{{Column: COLUMN_SIZE, SortOrder: sb.ASC}},
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatal("Files MUST be 3, found:", len(files))
+	}
+
+	if files[0].Path() != "/notes.md" || files[2].Path() != "/docs/2026/report.txt" {
+		t.Fatal("Files MUST be sorted by size, found:", files[0].Path(), files[2].Path())
+	}
+
+	page, err := store.RecordList(RecordQueryOptions{
+		Type:    TYPE_FILE,
+		OrderBy: []OrderClause{{Column: COLUMN_SIZE}},
+		Offset:  1,
+		Limit:   1,
+		Columns: []string{COLUMN_ID, COLUMN_PATH},
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(page) != 1 || page[0].Path() != "/docs/readme.txt" {
+		t.Fatal("unexpected page:", page)
+	}
+
+	if page[0].Contents() != "" || page[0].Name() != "" {
+		t.Fatal("Only the selected columns MUST be returned:", page[0].Data())
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs/"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 3 {
+		t.Fatal("Count MUST be 3, found:", count)
+	}
+}
+
+func TestMemoryStoreRecordsAreCopied(t *testing.T) {
+	store := initMemoryStore(t)
+
+	record, err := store.RecordFindByPath("/notes.md", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record.SetContents("CHANGED")
+
+	contents, err := store.ReadFile("/notes.md")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "NOTES" {
+		t.Fatal("Records MUST only change when updated, found:", string(contents))
+	}
+}
+
+func TestMemoryStoreSoftDelete(t *testing.T) {
+	store := initMemoryStore(t)
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDeleteRecursive(docs)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/docs/2026/report.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	trashed, err := store.TrashList(RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(trashed) != 4 {
+		t.Fatal("Trash MUST contain 4 records, found:", len(trashed))
+	}
+
+	err = store.RecordRestore(docs)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/docs/2026/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "REPORT 2026" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestMemoryStoreFileOperations(t *testing.T) {
+	store := initMemoryStore(t)
+
+	err := store.Rename("/docs", "/documents")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Copy("/documents", "/backup", CopyOptions{Recursive: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Remove("/documents")
+
+	if err == nil {
+		t.Fatal("must return error as the directory is not empty")
+	}
+
+	err = store.RemoveAll("/documents")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	writer, err := store.CreateWriter("/backup/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("STREAMED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = fstest.TestFS(store.FS(), "backup/readme.txt", "backup/2026/report.txt", "backup/2026/streamed.txt", "notes.md")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Stat("/documents/readme.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+}
+
+func TestMemoryStoreWithTxRollsBack(t *testing.T) {
+	store := initMemoryStore(t)
+
+	errFailed := errors.New("failed")
+
+	err := store.Transaction(context.Background(), func(tx StoreInterface) error {
+		err := tx.WriteFile("/docs/readme.txt", []byte("CHANGED"))
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.RemoveAll("/docs/2026")
+
+		if err != nil {
+			return err
+		}
+
+		return errFailed
+	})
+
+	if !errors.Is(err, errFailed) {
+		t.Fatal("expected the function error, found:", err)
+	}
+
+	contents, err := store.ReadFile("/docs/readme.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "README" {
+		t.Fatal("Changes MUST be rolled back, found:", string(contents))
+	}
+
+	_, err = store.Stat("/docs/2026/report.txt")
+
+	if err != nil {
+		t.Fatal("Changes MUST be rolled back:", err)
+	}
+}
+
+func TestMemoryStoreWithTxIsolated(t *testing.T) {
+	store := initMemoryStore(t)
+
+	errFailed := errors.New("failed")
+	started := make(chan struct{})
+	written := make(chan error)
+
+	go func() {
+		<-started
+		written <- store.WriteFile("/notes.md", []byte("CONCURRENT"))
+	}()
+
+	err := store.WithTx(context.Background(), func(tx *MemoryStore) error {
+		err := tx.WriteFile("/docs/readme.txt", []byte("CHANGED"))
+
+		if err != nil {
+			return err
+		}
+
+		close(started)
+
+		// The concurrent write waits for the transaction to end
+		time.Sleep(20 * time.Millisecond)
+
+		return errFailed
+	})
+
+	if !errors.Is(err, errFailed) {
+		t.Fatal("expected the function error, found:", err)
+	}
+
+	err = <-written
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for filePath, expected := range map[string]string{"/docs/readme.txt": "README", "/notes.md": "CONCURRENT"} {
+		contents, err := store.ReadFile(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if string(contents) != expected {
+			t.Fatal("The concurrent write MUST NOT be rolled back with the transaction, found:", filePath, string(contents))
+		}
+	}
+}
+
+func TestMemoryStoreWithCancelledContext(t *testing.T) {
+	store := initMemoryStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.RecordListWithContext(ctx, RecordQueryOptions{})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, found:", err)
+	}
+}
@@ -14,6 +14,57 @@ type NewStoreOptions struct {
 	DbDriverName       string
 	AutomigrateEnabled bool
 	DebugEnabled       bool
+
+	// BinaryContentsEnabled stores the contents in a binary column
+	// (BLOB / LONGBLOB / BYTEA) instead of a text column, so that binary
+	// files round-trip unchanged. Existing tables with a text column
+	// must be migrated with ContentsMigrateToBinary first.
+	BinaryContentsEnabled bool
+
+	// StrictModeEnabled makes RecordFindByID and RecordFindByPath
+	// return ErrNotFound instead of nil when there is no record
+	StrictModeEnabled bool
+
+	// AutoSuffixEnabled makes RecordCreate and Copy add a suffix to a
+	// name which is taken ("report (1).pdf"), instead of failing with
+	// ErrExists
+	AutoSuffixEnabled bool
+
+	// ContentTableEnabled keeps the contents of the files in the content
+	// table (the name of the record table, suffixed with "_content")
+	// instead of the record table, so that the record table holds the
+	// tree metadata only. The content rows are keyed by the hash of the
+	// contents and count their references, so that identical contents
+	// are kept once, copies share them, and a row is deleted with its
+	// last reference. The contents already in the record table are
+	// moved with ContentsMigrateToContentTable.
+	ContentTableEnabled bool
+
+	// Compression compresses the contents of the files, by default or by
+	// extension, see Compression. It requires BinaryContentsEnabled.
+	Compression Compression
+
+	// VersioningEnabled keeps a version of a file each time its contents
+	// are written, in the version table (the name of the record table,
+	// suffixed with "_version"), see VersionList
+	VersioningEnabled bool
+
+	// VersionRetention is the policy deciding which versions are kept,
+	// all of them by default
+	VersionRetention VersionRetention
+
+	// PathRules are the rules the paths and names are validated
+	// against, defaults to DefaultPathRules()
+	PathRules *PathRules
+
+	// CursorSecret is the secret the ListPage cursors are signed with.
+	// Defaults to a random secret, the cursors being valid for the
+	// lifetime of the store only.
+	CursorSecret []byte
+
+	// ChunkSize is the size in bytes of the chunks the streamed
+	// file contents are stored in, defaults to DEFAULT_CHUNK_SIZE
+	ChunkSize int
 }
 
 // NewStore creates a new block store
@@ -30,12 +81,48 @@ func NewStore(opts NewStoreOptions) (*Store, error) {
 		opts.DbDriverName = sb.DatabaseDriverName(opts.DB)
 	}
 
+	if err := opts.Compression.validate(); err != nil {
+		return nil, errors.New("file store: " + err.Error())
+	}
+
+	if opts.Compression.enabled() && !opts.BinaryContentsEnabled {
+		return nil, errors.New("file store: Compression requires BinaryContentsEnabled")
+	}
+
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DEFAULT_CHUNK_SIZE
+	}
+
+	if len(opts.CursorSecret) == 0 {
+		opts.CursorSecret = cursorSecretGenerate()
+	}
+
+	if opts.PathRules == nil {
+		rules := DefaultPathRules()
+		opts.PathRules = &rules
+	}
+
 	store := &Store{
-		tableName:          opts.TableName,
-		automigrateEnabled: opts.AutomigrateEnabled,
-		db:                 opts.DB,
-		dbDriverName:       opts.DbDriverName,
-		debugEnabled:       opts.DebugEnabled,
+		tableName:           opts.TableName,
+		chunkTableName:      opts.TableName + "_chunk",
+		contentTableName:    opts.TableName + "_content",
+		versionTableName:    opts.TableName + "_version",
+		chunkSize:           opts.ChunkSize,
+		automigrateEnabled:  opts.AutomigrateEnabled,
+		binaryContents:      opts.BinaryContentsEnabled,
+		db:                  opts.DB,
+		dbDriverName:        opts.DbDriverName,
+		contentTableEnabled: opts.ContentTableEnabled,
+		compression:         opts.Compression,
+		storeSettings: storeSettings{
+			debugEnabled:      opts.DebugEnabled,
+			strictModeEnabled: opts.StrictModeEnabled,
+			autoSuffixEnabled: opts.AutoSuffixEnabled,
+			pathRules:         *opts.PathRules,
+			cursorSecret:      opts.CursorSecret,
+			versioningEnabled: opts.VersioningEnabled,
+			versionRetention:  opts.VersionRetention,
+		},
 	}
 
 	if store.automigrateEnabled {
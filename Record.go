@@ -1,6 +1,7 @@
 package sqlfilestore
 
 import (
+	"path"
 	"strings"
 
 	"github.com/dromara/carbon/v2"
@@ -77,6 +78,49 @@ func (o *Record) SetContents(fileContents string) *Record {
 	return o
 }
 
+// contentsHydrate sets the contents loaded by the store, without marking
+// them as changed. The data is copied, so that the map returned by Data
+// before is left untouched.
+func (o *Record) contentsHydrate(fileContents string) {
+	data := copyData(o.Data())
+	data["contents"] = fileContents
+	o.Hydrate(data)
+}
+
+// ContentsBytes returns the contents as a byte slice,
+// to be used for binary files (images, PDFs, etc.)
+func (o *Record) ContentsBytes() []byte {
+	return []byte(o.Contents())
+}
+
+// SetContentsBytes sets the contents from a byte slice,
+// to be used for binary files (images, PDFs, etc.)
+func (o *Record) SetContentsBytes(fileContents []byte) *Record {
+	return o.SetContents(string(fileContents))
+}
+
+// ContentID returns the ID of the row of the content table the contents
+// are kept in, empty if they are kept in the record
+func (o *Record) ContentID() string {
+	return o.Get(COLUMN_CONTENT_ID)
+}
+
+func (o *Record) SetContentID(contentID string) *Record {
+	o.Set(COLUMN_CONTENT_ID, contentID)
+	return o
+}
+
+// Hash returns the hex encoded SHA-256 of the contents, computed when the
+// contents are written, empty for the records written before
+func (o *Record) Hash() string {
+	return o.Get(COLUMN_HASH)
+}
+
+func (o *Record) SetHash(hash string) *Record {
+	o.Set(COLUMN_HASH, hash)
+	return o
+}
+
 func (o *Record) CreatedAt() string {
 	return o.Get("created_at")
 }
@@ -140,7 +184,7 @@ func (o *Record) Path() string {
 // Any trailing spaces is also trimmed
 func (o *Record) SetPath(filePath string) *Record {
 	filePath = strings.TrimSpace(filePath)
-	filePath = "/" + strings.TrimLeft(filePath, "/")
+	filePath = path.Clean("/" + strings.TrimLeft(filePath, "/"))
 	o.Set("path", filePath)
 	return o
 }
@@ -1,8 +1,10 @@
 package sqlfilestore
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"io"
 	"log"
 	"strconv"
 	"strings"
@@ -13,25 +15,30 @@ import (
 	"github.com/samber/lo"
 )
 
-// var _ StoreInterface = (*Store)(nil) // verify it extends the interface
+var _ StoreInterface = (*Store)(nil) // verify it extends the interface
 
 type Store struct {
 	tableName          string
+	chunkTableName     string
+	contentTableName   string
+	versionTableName   string
+	chunkSize          int
 	db                 *sql.DB
+	tx                 *sql.Tx
+	ctx                context.Context
 	dbDriverName       string
 	automigrateEnabled bool
-	debugEnabled       bool
+	binaryContents     bool
+
+	contentTableEnabled bool
+	compression         Compression
+
+	storeSettings
 }
 
 // AutoMigrate auto migrate
 func (store *Store) AutoMigrate() error {
-	sql := store.sqlTableCreate()
-
-	if sql == "" {
-		return errors.New("record table create sql is empty")
-	}
-
-	_, err := store.db.Exec(sql)
+	err := store.migrate()
 
 	if err != nil {
 		return err
@@ -64,47 +71,38 @@ func (store *Store) AutoMigrate() error {
 	return nil
 }
 
-// EnableDebug - enables the debug option
-func (st *Store) EnableDebug(debug bool) {
-	st.debugEnabled = debug
-}
-
-func (store *Store) RecordRecalculatePath(record *Record, parentRecord *Record) error {
-	if record == nil {
-		return errors.New("record is nil")
-	}
+// ContentsMigrateToBinary converts the contents column of an existing table
+// (and of its content table) from text to binary, keeping the stored
+// contents. It is meant to be run once, before enabling the
+// BinaryContentsEnabled option on the store.
+func (store *Store) ContentsMigrateToBinary() error {
+	tableNames := []string{store.tableName}
 
-	if parentRecord == nil {
-		parentRecord, err := store.RecordFindByID(record.ParentID(), RecordQueryOptions{Columns: []string{"id", "path"}})
+	// The tables created by the later migrations, if any
+	for _, tableName := range []string{store.contentTableName, store.versionTableName} {
+		exists, err := store.tableExists(tableName)
 
 		if err != nil {
 			return err
 		}
 
-		if parentRecord == nil {
-			return errors.New("parent record not found")
+		if exists {
+			tableNames = append(tableNames, tableName)
 		}
 	}
 
-	record.SetPath(parentRecord.Path() + PATH_SEPARATOR + record.Name())
+	for _, tableName := range tableNames {
+		sqlStr, err := store.sqlContentsToBinary(tableName)
 
-	err := store.RecordUpdate(record)
-
-	if err != nil {
-		return err
-	}
-
-	children, err := store.RecordList(RecordQueryOptions{
-		ParentID: record.ID(),
-		Columns:  []string{"id", "path"},
-	})
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		if store.debugEnabled {
+			log.Println(sqlStr)
+		}
 
-	for _, child := range children {
-		err = store.RecordRecalculatePath(&child, record)
+		_, err = store.executeSql(sqlStr)
 
 		if err != nil {
 			return err
@@ -114,27 +112,81 @@ func (store *Store) RecordRecalculatePath(record *Record, parentRecord *Record)
 	return nil
 }
 
+// EnableDebug - enables the debug option
+func (st *Store) EnableDebug(debug bool) {
+	st.debugEnabled = debug
+}
+
+// EnableStrictMode - enables the strict mode, in which RecordFindByID
+// and RecordFindByPath return ErrNotFound instead of nil
+func (store *Store) EnableStrictMode(strict bool) {
+	store.strictModeEnabled = strict
+}
+
 func (store *Store) RecordCreate(record *Record) error {
+	return store.recordCreate(record, store.autoSuffixEnabled)
+}
+
+// recordCreate inserts the record, with autoSuffix adding a suffix
+// to its name if it is taken instead of failing with ErrExists
+func (store *Store) recordCreate(record *Record, autoSuffix bool) error {
 	record.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
 	record.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
 
-	data := record.Data()
+	hashOnWrite(record, record.Data(), "")
 
-	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
-		Insert(store.tableName).
-		Prepared(true).
-		Rows(data).
-		ToSQL()
+	err := store.pathRules.validateData(record.Data())
 
-	if errSql != nil {
-		return errSql
+	if err != nil {
+		return err
 	}
 
-	if store.debugEnabled {
-		log.Println(sqlStr)
-	}
+	err = store.transaction(func(txStore *Store) error {
+		err := txStore.recordEnsureUnique(record, autoSuffix)
 
-	_, err := store.db.Exec(sqlStr, params...)
+		if err != nil {
+			return err
+		}
+
+		data := txStore.recordValues(record.Data())
+
+		if contents, ok := record.Data()[COLUMN_CONTENTS]; ok && (txStore.contentTableEnabled || txStore.compression.enabled()) {
+			err = txStore.contentsSeparate(data, contents, record.Hash(), txStore.contentsCodec(record))
+
+			if err != nil {
+				return err
+			}
+
+			record.SetContentID(data[COLUMN_CONTENT_ID].(string))
+		}
+
+		sqlStr, params, errSql := goqu.Dialect(txStore.dbDriverName).
+			Insert(txStore.tableName).
+			Prepared(true).
+			Rows(data).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = txStore.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return txStore.uniqueError(err, record.Path())
+		}
+
+		// A new file without contents has no version until written
+		if !txStore.versioningEnabled || !record.IsFile() || record.Contents() == "" {
+			return nil
+		}
+
+		return txStore.versionCreate(record.ID(), VersionOptions{})
+	})
 
 	if err != nil {
 		return err
@@ -159,8 +211,7 @@ func (st *Store) RecordCount(options RecordQueryOptions) (int64, error) {
 		log.Println(sqlStr)
 	}
 
-	db := sb.NewDatabase(st.db, st.dbDriverName)
-	mapped, err := db.SelectToMapString(sqlStr)
+	mapped, err := st.selectToMapString(sqlStr)
 	if err != nil {
 		return -1, err
 	}
@@ -191,7 +242,7 @@ func (store *Store) RecordDelete(record *Record) error {
 
 func (store *Store) RecordDeleteByID(id string) error {
 	if id == "" {
-		return errors.New("record id is empty")
+		return ErrEmptyID
 	}
 
 	subsCount, err := store.RecordCount(RecordQueryOptions{
@@ -205,7 +256,7 @@ func (store *Store) RecordDeleteByID(id string) error {
 	}
 
 	if subsCount > 0 {
-		return errors.New("directory is not empty")
+		return ErrNotEmpty
 	}
 
 	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
@@ -222,17 +273,39 @@ func (store *Store) RecordDeleteByID(id string) error {
 		log.Println(sqlStr)
 	}
 
-	_, err = store.db.Exec(sqlStr, params...)
+	return store.transaction(func(txStore *Store) error {
+		err := txStore.versionsDelete(goqu.C(COLUMN_ID).Eq(id))
 
-	return err
+		if err != nil {
+			return err
+		}
+
+		err = txStore.contentsRelease(txStore.tableName, goqu.C(COLUMN_ID).Eq(id))
+
+		if err != nil {
+			return err
+		}
+
+		_, err = txStore.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+
+		return txStore.chunksDelete(id)
+	})
 }
 
-func (store *Store) RecordFindByPath(path string, options RecordQueryOptions) (*Record, error) {
+func (store *Store) recordFindByPath(path string, options RecordQueryOptions) (*Record, error) {
 	if path == "" {
-		return nil, errors.New("record path is empty")
+		return nil, wrapError(ErrInvalidPath, "record path is empty")
 	}
 
-	path = store.fixPath(path)
+	path, err := store.pathRules.CleanPath(path)
+
+	if err != nil {
+		return nil, err
+	}
 
 	options.Path = path
 	options.Limit = 1
@@ -250,9 +323,9 @@ func (store *Store) RecordFindByPath(path string, options RecordQueryOptions) (*
 	return nil, nil
 }
 
-func (store *Store) RecordFindByID(id string, options RecordQueryOptions) (*Record, error) {
+func (store *Store) recordFindByID(id string, options RecordQueryOptions) (*Record, error) {
 	if id == "" {
-		return nil, errors.New("record id is empty")
+		return nil, ErrEmptyID
 	}
 
 	options.ID = id
@@ -271,18 +344,49 @@ func (store *Store) RecordFindByID(id string, options RecordQueryOptions) (*Reco
 	return nil, nil
 }
 
+// RecordFindByPath returns the live record with the path, or nil if there
+// is none. In strict mode ErrNotFound is returned instead of nil.
+func (store *Store) RecordFindByPath(path string, options RecordQueryOptions) (*Record, error) {
+	record, err := store.recordFindByPath(path, options)
+
+	if err == nil && record == nil && store.strictModeEnabled {
+		return nil, wrapError(ErrNotFound, "record not found: "+path)
+	}
+
+	return record, err
+}
+
+// RecordFindByID returns the record with the ID, or nil if there is none.
+// In strict mode ErrNotFound is returned instead of nil.
+func (store *Store) RecordFindByID(id string, options RecordQueryOptions) (*Record, error) {
+	record, err := store.recordFindByID(id, options)
+
+	if err == nil && record == nil && store.strictModeEnabled {
+		return nil, wrapError(ErrNotFound, "record not found: "+id)
+	}
+
+	return record, err
+}
+
 func (store *Store) RecordList(options RecordQueryOptions) ([]Record, error) {
 	q := store.recordQuery(options)
 
 	if len(options.Columns) > 0 {
-		q = q.Select(options.Columns[0])
-		if len(options.Columns) > 1 {
-			for _, column := range options.Columns[1:] {
+		columns := contentsColumns(options.Columns)
+
+		q = q.Select(columns[0])
+		if len(columns) > 1 {
+			for _, column := range columns[1:] {
 				q = q.SelectAppend(goqu.C(column))
 			}
 		}
-	} else {
+	} else if options.WithContents {
 		q = q.Select(goqu.Star())
+	} else {
+		// The contents are loaded on demand, see RecordLoadContents
+		q = q.Select(lo.Map(fsMetadataColumns, func(column string, _ int) any {
+			return goqu.C(column)
+		})...)
 	}
 
 	sqlStr, _, errSql := q.ToSQL()
@@ -295,8 +399,7 @@ func (store *Store) RecordList(options RecordQueryOptions) ([]Record, error) {
 		log.Println(sqlStr)
 	}
 
-	db := sb.NewDatabase(store.db, store.dbDriverName)
-	modelMaps, err := db.SelectToMapString(sqlStr)
+	modelMaps, err := store.selectToMapString(sqlStr)
 	if err != nil {
 		return []Record{}, err
 	}
@@ -308,9 +411,44 @@ func (store *Store) RecordList(options RecordQueryOptions) ([]Record, error) {
 		list = append(list, *model)
 	})
 
+	if lo.Contains(options.Columns, COLUMN_CONTENTS) || (len(options.Columns) == 0 && options.WithContents) {
+		err = store.contentsLoad(list)
+
+		if err != nil {
+			return []Record{}, err
+		}
+	}
+
 	return list, nil
 }
 
+// RecordLoadContents loads the contents of a record found or listed without
+// them, from the chunks if the file has been streamed. The record is not
+// marked as changed.
+func (store *Store) RecordLoadContents(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	reader, err := store.recordReader(record)
+
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+
+	if err != nil {
+		return err
+	}
+
+	record.contentsHydrate(string(contents))
+
+	return nil
+}
+
 func (store *Store) RecordSoftDelete(record *Record) error {
 	if record == nil {
 		return errors.New("record is nil")
@@ -322,22 +460,37 @@ func (store *Store) RecordSoftDelete(record *Record) error {
 }
 
 func (store *Store) RecordSoftDeleteByID(id string) error {
-	record, err := store.RecordFindByID(id, RecordQueryOptions{Columns: []string{"id", "deleted_at"}})
+	record, err := store.recordFindByID(id, RecordQueryOptions{Columns: []string{"id", "deleted_at"}})
 
 	if err != nil {
 		return err
 	}
 
+	if record == nil {
+		return ErrNotFound
+	}
+
 	return store.RecordSoftDelete(record)
 }
 
+// RecordUpdate writes the changed fields of the record. A change of the
+// contents is kept as a new version of the file, when versioning is enabled.
 func (store *Store) RecordUpdate(record *Record) error {
+	return store.RecordUpdateVersioned(record, VersionOptions{})
+}
+
+// recordUpdate writes the changed fields of the record, a change of the
+// contents being kept as a new version with the options, if any. The hash
+// of changed contents is computed from them, unless given.
+func (store *Store) recordUpdate(record *Record, hash string, version *VersionOptions) error {
 	if record == nil {
 		return errors.New("record is nil")
 	}
 
 	record.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString())
 
+	hashOnWrite(record, record.DataChanged(), hash)
+
 	dataChanged := record.DataChanged()
 
 	delete(dataChanged, "id") // ID is not updateable
@@ -346,11 +499,81 @@ func (store *Store) RecordUpdate(record *Record) error {
 		return nil
 	}
 
+	err := store.pathRules.validateData(dataChanged)
+
+	if err != nil {
+		return err
+	}
+
+	values := store.recordValues(dataChanged)
+
+	contents, contentsChanged := dataChanged[COLUMN_CONTENTS]
+	locationChanged := lo.Some(lo.Keys(dataChanged), []string{COLUMN_PARENT_ID, COLUMN_NAME, COLUMN_PATH, COLUMN_DELETED_AT})
+
+	if contentsChanged || locationChanged {
+		err = store.transaction(func(txStore *Store) error {
+			if locationChanged {
+				err := txStore.recordUpdateEnsureUnique(record.ID(), dataChanged)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if contentsChanged {
+				// New contents release the content row of the previous ones
+				err := txStore.contentsRelease(txStore.tableName, goqu.C(COLUMN_ID).Eq(record.ID()))
+
+				if err != nil {
+					return err
+				}
+
+				err = txStore.contentsSeparate(values, contents, record.Hash(), txStore.contentsCodec(record))
+
+				if err != nil {
+					return err
+				}
+			}
+
+			err := txStore.recordUpdateValues(record.ID(), values)
+
+			if err != nil {
+				return txStore.uniqueError(err, record.Path())
+			}
+
+			if !contentsChanged {
+				return nil
+			}
+
+			// New contents replace any contents previously streamed in chunks
+			err = txStore.chunksDelete(record.ID())
+
+			if err != nil || version == nil {
+				return err
+			}
+
+			return txStore.versionCreate(record.ID(), *version)
+		})
+	} else {
+		err = store.recordUpdateValues(record.ID(), values)
+	}
+
+	if err == nil && contentsChanged {
+		record.SetContentID(values[COLUMN_CONTENT_ID].(string))
+	}
+
+	record.MarkAsNotDirty()
+
+	return err
+}
+
+// recordUpdateValues writes the values to the record with the ID
+func (store *Store) recordUpdateValues(id string, values goqu.Record) error {
 	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
 		Update(store.tableName).
 		Prepared(true).
-		Set(dataChanged).
-		Where(goqu.C("id").Eq(record.ID())).
+		Set(values).
+		Where(goqu.C("id").Eq(id)).
 		ToSQL()
 
 	if errSql != nil {
@@ -361,9 +584,7 @@ func (store *Store) RecordUpdate(record *Record) error {
 		log.Println(sqlStr)
 	}
 
-	_, err := store.db.Exec(sqlStr, params...)
-
-	record.MarkAsNotDirty()
+	_, err := store.executeSql(sqlStr, params...)
 
 	return err
 }
@@ -383,6 +604,34 @@ func (store *Store) recordQuery(options RecordQueryOptions) *goqu.SelectDataset
 		q = q.Where(goqu.C("parent_id").Eq(options.ParentID))
 	}
 
+	if len(options.ParentIDIn) > 0 {
+		q = q.Where(goqu.C("parent_id").In(options.ParentIDIn))
+	}
+
+	if options.NameLike != "" {
+		q = q.Where(likeCondition(COLUMN_NAME, options.NameLike))
+	}
+
+	if len(options.NameIn) > 0 {
+		q = q.Where(goqu.C("name").In(options.NameIn))
+	}
+
+	if options.Extension != "" {
+		q = q.Where(goqu.C("extension").Eq(options.Extension))
+	}
+
+	if len(options.ExtensionIn) > 0 {
+		q = q.Where(goqu.C("extension").In(options.ExtensionIn))
+	}
+
+	if options.SizeGreaterThan != "" {
+		q = q.Where(goqu.C("size").Gt(options.SizeGreaterThan))
+	}
+
+	if options.SizeLessThan != "" {
+		q = q.Where(goqu.C("size").Lt(options.SizeLessThan))
+	}
+
 	if options.CreatedAtGreaterThan != "" {
 		q = q.Where(goqu.C("created_at").Gt(options.CreatedAtGreaterThan))
 	}
@@ -399,6 +648,14 @@ func (store *Store) recordQuery(options RecordQueryOptions) *goqu.SelectDataset
 		q = q.Where(goqu.C("updated_at").Lt(options.UpdatedAtLessThan))
 	}
 
+	if options.DeletedAtGreaterThan != "" {
+		q = q.Where(goqu.C("deleted_at").Gt(options.DeletedAtGreaterThan))
+	}
+
+	if options.DeletedAtLessThan != "" {
+		q = q.Where(goqu.C("deleted_at").Lt(options.DeletedAtLessThan))
+	}
+
 	if options.Type != "" {
 		q = q.Where(goqu.C("type").Eq(options.Type))
 	}
@@ -407,8 +664,13 @@ func (store *Store) recordQuery(options RecordQueryOptions) *goqu.SelectDataset
 		q = q.Where(goqu.C("path").Eq(options.Path))
 	}
 
+	if len(options.PathIn) > 0 {
+		q = q.Where(goqu.C("path").In(options.PathIn))
+	}
+
 	if options.PathStartsWith != "" {
-		q = q.Where(goqu.C("path").Like(options.PathStartsWith + "%"))
+		// The wildcards in the prefix are matched literally
+		q = q.Where(pathLike(likeEscape(options.PathStartsWith) + "%"))
 	}
 
 	if !options.CountOnly {
@@ -421,50 +683,90 @@ func (store *Store) recordQuery(options RecordQueryOptions) *goqu.SelectDataset
 		}
 	}
 
-	sortOrder := "desc"
-	if options.SortOrder != "" {
-		sortOrder = options.SortOrder
-	}
-
-	if options.OrderBy != "" {
-		if strings.EqualFold(sortOrder, sb.ASC) {
-			q = q.Order(goqu.I(options.OrderBy).Asc())
-		} else {
-			q = q.Order(goqu.I(options.OrderBy).Desc())
+	// The order is not needed to count (nor allowed by PostgreSQL)
+	if !options.CountOnly {
+		for _, clause := range options.OrderBy {
+			if clause.IsAscending() {
+				q = q.OrderAppend(goqu.I(clause.Column).Asc())
+			} else {
+				q = q.OrderAppend(goqu.I(clause.Column).Desc())
+			}
 		}
 	}
 
-	if !options.WithSoftDeleted {
+	if options.OnlySoftDeleted {
+		q = q.Where(goqu.C("deleted_at").Neq(sb.NULL_DATETIME))
+	} else if !options.WithSoftDeleted {
 		q = q.Where(goqu.C("deleted_at").Eq(sb.NULL_DATETIME))
 	}
 
 	return q
 }
 
-func (store *Store) fixPath(path string) string {
-	if strings.HasPrefix(path, PATH_SEPARATOR) {
-		return path
+// recordValues converts the record data to the values to be written,
+// the contents being passed as bytes if stored in a binary column
+func (store *Store) recordValues(data map[string]string) goqu.Record {
+	values := goqu.Record{}
+
+	for key, value := range data {
+		if key == COLUMN_CONTENTS {
+			values[key] = store.contentsValue(value)
+			continue
+		}
+
+		values[key] = value
 	}
 
-	return PATH_SEPARATOR + path
+	return values
 }
 
 type RecordQueryOptions struct {
-	ID                   string
-	IDIn                 []string
-	ParentID             string
+	ID         string
+	IDIn       []string
+	ParentID   string
+	ParentIDIn []string
+	// NameLike is a LIKE pattern, "%" matching any number of characters
+	// and "_" a single one, escaped with "!" (the case sensitivity
+	// depends on the database)
+	NameLike    string
+	NameIn      []string
+	Extension   string
+	ExtensionIn []string
+	// SizeGreaterThan and SizeLessThan are sizes in bytes, as the record
+	// sizes are, compared as numbers
+	SizeGreaterThan      string
+	SizeLessThan         string
 	Type                 string
 	Path                 string
+	PathIn               []string
 	PathStartsWith       string
 	CreatedAtLessThan    string
 	CreatedAtGreaterThan string
 	UpdatedAtLessThan    string
 	UpdatedAtGreaterThan string
+	DeletedAtLessThan    string
+	DeletedAtGreaterThan string
 	Columns              []string
 	Offset               int
 	Limit                int
-	SortOrder            string
-	OrderBy              string
+	OrderBy              []OrderClause
 	CountOnly            bool
 	WithSoftDeleted      bool
+	// WithContents selects the contents too, when no Columns are set.
+	// By default the records are found and listed without the contents.
+	WithContents bool
+	// OnlySoftDeleted selects the soft deleted records only
+	OnlySoftDeleted bool
+}
+
+// OrderClause orders the records by a column, in the sort order
+// (sb.ASC or sb.DESC, descending if empty)
+type OrderClause struct {
+	Column    string
+	SortOrder string
+}
+
+// IsAscending returns whether the records are sorted in ascending order
+func (clause OrderClause) IsAscending() bool {
+	return strings.EqualFold(clause.SortOrder, sb.ASC)
 }
@@ -0,0 +1,159 @@
+package sqlfilestore
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// StoreInterface is the interface implemented by the SQL backed Store,
+// and by the in-memory MemoryStore to be used in tests
+type StoreInterface interface {
+	// AutoMigrate creates the tables (if missing) and the root directory
+	AutoMigrate() error
+
+	// ContentsMigrateToBinary converts the contents column from text to binary
+	ContentsMigrateToBinary() error
+
+	// ContentsMigrateToContentTable moves the contents to the content table
+	ContentsMigrateToContentTable() error
+
+	// MigrationsPending returns the schema migrations AutoMigrate would apply
+	MigrationsPending() ([]Migration, error)
+
+	// EnableAutoSuffix makes RecordCreate and Copy add a suffix to a taken name
+	EnableAutoSuffix(enabled bool)
+
+	// EnableDebug enables or disables the debug option
+	EnableDebug(debug bool)
+
+	// EnableStrictMode makes the Find methods return ErrNotFound instead of nil
+	EnableStrictMode(strict bool)
+
+	// EnableVersioning keeps a version of a file each time its contents are written
+	EnableVersioning(enabled bool)
+
+	// SetCursorSecret sets the secret the ListPage cursors are signed with
+	SetCursorSecret(secret []byte)
+
+	// SetPathRules sets the rules the paths and names are validated against
+	SetPathRules(rules PathRules)
+
+	// SetVersionRetention sets the policy deciding which versions are kept
+	SetVersionRetention(retention VersionRetention)
+
+	// FS returns a read-only io/fs view of the store
+	FS() *FS
+
+	// == TRANSACTIONS =======================================================
+
+	// Transaction executes the function in a transaction
+	Transaction(ctx context.Context, fn func(tx StoreInterface) error) error
+
+	// == RECORDS ============================================================
+
+	ListPage(options RecordQueryOptions, cursor string) ([]Record, string, error)
+	RecordCount(options RecordQueryOptions) (int64, error)
+	RecordCreate(record *Record) error
+	RecordDelete(record *Record) error
+	RecordDeleteByID(id string) error
+	RecordDeleteRecursive(record *Record) error
+	RecordFindByID(id string, options RecordQueryOptions) (*Record, error)
+	RecordFindByPath(path string, options RecordQueryOptions) (*Record, error)
+	RecordList(options RecordQueryOptions) ([]Record, error)
+	RecordLoadContents(record *Record) error
+	RecordRecalculatePath(record *Record, parentRecord *Record) error
+	RecordRestore(record *Record) error
+	RecordRestoreByID(id string) error
+	RecordSoftDelete(record *Record) error
+	RecordSoftDeleteByID(id string) error
+	RecordSoftDeleteRecursive(record *Record) error
+	RecordUpdate(record *Record) error
+	RecordUpdateVersioned(record *Record, options VersionOptions) error
+
+	// == DIRECTORIES ========================================================
+
+	DirectoryEnsure(dirPath string) (*Record, error)
+	Glob(pattern string) ([]Record, error)
+	ListRecursive(root string, options ListOptions) ([]Record, error)
+	Walk(root string, fn WalkFunc) error
+
+	// == VERSIONS ===========================================================
+
+	VersionGet(id string, number int) (*Version, error)
+	VersionList(id string) ([]Version, error)
+	VersionPurge() (int, error)
+	VersionRestore(id string, number int) error
+
+	// == TRASH ==============================================================
+
+	TrashList(options RecordQueryOptions) ([]Record, error)
+	TrashPurge(olderThan time.Duration) (int, error)
+
+	// == FILES ==============================================================
+
+	Copy(srcPath string, dstPath string, options CopyOptions) error
+	Create(filePath string) (*Record, error)
+	CreateWriter(filePath string) (io.WriteCloser, error)
+	Mkdir(dirPath string) error
+	MkdirAll(dirPath string) error
+	Move(id string, newParentID string, newName string) error
+	Open(filePath string) (io.ReadSeekCloser, error)
+	OpenFile(filePath string, flag int) (*Record, error)
+	ReadFile(filePath string) ([]byte, error)
+	Remove(filePath string) error
+	RemoveAll(filePath string) error
+	Rename(oldPath string, newPath string) error
+	Stat(filePath string) (fs.FileInfo, error)
+	WriteFile(filePath string, data []byte) error
+
+	// == WITH CONTEXT =======================================================
+
+	AutoMigrateWithContext(ctx context.Context) error
+	ContentsMigrateToBinaryWithContext(ctx context.Context) error
+	ContentsMigrateToContentTableWithContext(ctx context.Context) error
+	CopyWithContext(ctx context.Context, srcPath string, dstPath string, options CopyOptions) error
+	CreateWithContext(ctx context.Context, filePath string) (*Record, error)
+	CreateWriterWithContext(ctx context.Context, filePath string) (io.WriteCloser, error)
+	DirectoryEnsureWithContext(ctx context.Context, dirPath string) (*Record, error)
+	GlobWithContext(ctx context.Context, pattern string) ([]Record, error)
+	ListPageWithContext(ctx context.Context, options RecordQueryOptions, cursor string) ([]Record, string, error)
+	ListRecursiveWithContext(ctx context.Context, root string, options ListOptions) ([]Record, error)
+	MigrationsPendingWithContext(ctx context.Context) ([]Migration, error)
+	MkdirWithContext(ctx context.Context, dirPath string) error
+	MkdirAllWithContext(ctx context.Context, dirPath string) error
+	MoveWithContext(ctx context.Context, id string, newParentID string, newName string) error
+	OpenWithContext(ctx context.Context, filePath string) (io.ReadSeekCloser, error)
+	OpenFileWithContext(ctx context.Context, filePath string, flag int) (*Record, error)
+	ReadFileWithContext(ctx context.Context, filePath string) ([]byte, error)
+	RecordCountWithContext(ctx context.Context, options RecordQueryOptions) (int64, error)
+	RecordCreateWithContext(ctx context.Context, record *Record) error
+	RecordDeleteWithContext(ctx context.Context, record *Record) error
+	RecordDeleteByIDWithContext(ctx context.Context, id string) error
+	RecordDeleteRecursiveWithContext(ctx context.Context, record *Record) error
+	RecordFindByIDWithContext(ctx context.Context, id string, options RecordQueryOptions) (*Record, error)
+	RecordFindByPathWithContext(ctx context.Context, path string, options RecordQueryOptions) (*Record, error)
+	RecordListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error)
+	RecordLoadContentsWithContext(ctx context.Context, record *Record) error
+	RecordRecalculatePathWithContext(ctx context.Context, record *Record, parentRecord *Record) error
+	RecordRestoreWithContext(ctx context.Context, record *Record) error
+	RecordRestoreByIDWithContext(ctx context.Context, id string) error
+	RecordSoftDeleteWithContext(ctx context.Context, record *Record) error
+	RecordSoftDeleteByIDWithContext(ctx context.Context, id string) error
+	RecordSoftDeleteRecursiveWithContext(ctx context.Context, record *Record) error
+	RecordUpdateWithContext(ctx context.Context, record *Record) error
+	RecordUpdateVersionedWithContext(ctx context.Context, record *Record, options VersionOptions) error
+	RemoveWithContext(ctx context.Context, filePath string) error
+	RemoveAllWithContext(ctx context.Context, filePath string) error
+	RenameWithContext(ctx context.Context, oldPath string, newPath string) error
+	StatWithContext(ctx context.Context, filePath string) (fs.FileInfo, error)
+	TrashListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error)
+	TrashPurgeWithContext(ctx context.Context, olderThan time.Duration) (int, error)
+	VersionGetWithContext(ctx context.Context, id string, number int) (*Version, error)
+	VersionListWithContext(ctx context.Context, id string) ([]Version, error)
+	VersionPurgeWithContext(ctx context.Context) (int, error)
+	VersionRestoreWithContext(ctx context.Context, id string, number int) error
+	WalkWithContext(ctx context.Context, root string, fn WalkFunc) error
+	WriteFileWithContext(ctx context.Context, filePath string, data []byte) error
+}
@@ -1,6 +1,7 @@
 package sqlfilestore
 
 import (
+	"bytes"
 	"database/sql"
 	"os"
 	"strings"
@@ -23,6 +24,24 @@ func initDB(filepath string) *sql.DB {
 	return db
 }
 
+// initStoreWithOptions returns a migrated store on a single connection to
+// an in-memory database, configured with the options
+func initStoreWithOptions(t *testing.T, options NewStoreOptions) *Store {
+	options.DB = initDB(":memory:")
+	options.AutomigrateEnabled = true
+
+	// A single connection, as each connection opens its own in-memory database
+	options.DB.SetMaxOpenConns(1)
+
+	store, err := NewStore(options)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
 func TestStoreRootCreated(t *testing.T) {
 	db := initDB(":memory:")
 
@@ -374,3 +393,139 @@ func TestStoreFolderDeleteWithSubs(t *testing.T) {
 		t.Fatal("unexpected error:", err)
 	}
 }
+
+func TestStoreBinaryContents(t *testing.T) {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                    db,
+		TableName:             "file_binary_contents",
+		AutomigrateEnabled:    true,
+		BinaryContentsEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data := []byte{0x89, 'P', 'N', 'G', 0x00, 0x00, 0xff, 0xfe, 0x0d, 0x0a, 0x00}
+
+	file := NewFile().
+		SetParentID(ROOT_ID).
+		SetName("image.png").
+		SetPath(ROOT_PATH + "image.png").
+		SetSize(utils.ToString(len(data))).
+		SetExtension("png").
+		SetContentsBytes(data)
+
+	err = store.RecordCreate(file)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	fileFound, err := store.RecordFindByID(file.ID(), RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(fileFound.ContentsBytes(), data) {
+		t.Fatal("Contents do not match", data, "found:", fileFound.ContentsBytes())
+	}
+
+	var storageType string
+	err = db.QueryRow(`SELECT typeof(contents) FROM file_binary_contents WHERE id = ?`, file.ID()).Scan(&storageType)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storageType != "blob" {
+		t.Fatal("Contents MUST be stored as blob, found:", storageType)
+	}
+
+	fileFound.SetContentsBytes(append(data, 0x00, 0x01))
+
+	err = store.RecordUpdate(fileFound)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	fileFound, err = store.RecordFindByID(file.ID(), RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(fileFound.ContentsBytes(), append(data, 0x00, 0x01)) {
+		t.Fatal("Contents do not match after update, found:", fileFound.ContentsBytes())
+	}
+}
+
+func TestStoreContentsMigrateToBinary(t *testing.T) {
+	db := initDB(":memory:")
+
+	textStore, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          "file_contents_migrate",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	file := NewFile().
+		SetParentID(ROOT_ID).
+		SetName("test.txt").
+		SetPath(ROOT_PATH + "test.txt").
+		SetSize(utils.ToString(len([]byte("TEST")))).
+		SetExtension("txt").
+		SetContents("TEST")
+
+	err = textStore.RecordCreate(file)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = textStore.ContentsMigrateToBinary()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	binaryStore, err := NewStore(NewStoreOptions{
+		DB:                    db,
+		TableName:             "file_contents_migrate",
+		AutomigrateEnabled:    true,
+		BinaryContentsEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var storageType string
+	err = db.QueryRow(`SELECT typeof(contents) FROM file_contents_migrate WHERE id = ?`, file.ID()).Scan(&storageType)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storageType != "blob" {
+		t.Fatal("Contents MUST be stored as blob, found:", storageType)
+	}
+
+	fileFound, err := binaryStore.RecordFindByID(file.ID(), RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if fileFound.Contents() != "TEST" {
+		t.Fatal("Contents do not match, found:", fileFound.Contents())
+	}
+}
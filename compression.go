@@ -0,0 +1,116 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Compression are the options compressing the contents of the files as
+// they are written with RecordCreate and RecordUpdate, and decompressing
+// them as they are read. The codec is kept with the contents, so that
+// compressed and uncompressed contents coexist, and the size of a record
+// remains the size of its uncompressed contents.
+//
+// The contents are kept uncompressed when compressing them does not make
+// them smaller. The streamed contents are not compressed.
+type Compression struct {
+	// Codec is the codec the contents of all the files are compressed
+	// with, CODEC_NONE by default
+	Codec string
+
+	// Extensions are the codecs by file extension (lower case, without
+	// the dot), overriding Codec, i.e. {"json": CODEC_GZIP, "css":
+	// CODEC_GZIP} to compress only the text assets, or {"png": CODEC_NONE}
+	// to keep the already compressed files as they are
+	Extensions map[string]string
+}
+
+// codecs are the supported codecs
+var codecs = []string{CODEC_NONE, CODEC_GZIP}
+
+// validate checks that the codecs of the compression are supported
+func (compression Compression) validate() error {
+	for _, codec := range append([]string{compression.Codec}, lo.Values(compression.Extensions)...) {
+		if !slices.Contains(codecs, codec) {
+			return errors.New("unsupported codec: " + codec)
+		}
+	}
+
+	return nil
+}
+
+// codec returns the codec the contents of a file with the extension are
+// compressed with
+func (compression Compression) codec(extension string) string {
+	if codec, ok := compression.Extensions[strings.ToLower(extension)]; ok {
+		return codec
+	}
+
+	return compression.Codec
+}
+
+// enabled returns whether any contents are compressed
+func (compression Compression) enabled() bool {
+	return compression.Codec != CODEC_NONE || lo.SomeBy(lo.Values(compression.Extensions), func(codec string) bool {
+		return codec != CODEC_NONE
+	})
+}
+
+// contentsEncode returns the contents compressed with the codec, and the
+// codec they are compressed with, CODEC_NONE if compressing them does not
+// make them smaller
+func contentsEncode(contents string, codec string) (string, string, error) {
+	if codec == CODEC_NONE || contents == "" {
+		return contents, CODEC_NONE, nil
+	}
+
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+
+	_, err := writer.Write([]byte(contents))
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if buffer.Len() >= len(contents) {
+		return contents, CODEC_NONE, nil
+	}
+
+	return buffer.String(), codec, nil
+}
+
+// contentsDecode returns the contents compressed with the codec,
+// decompressed
+func contentsDecode(contents string, codec string) (string, error) {
+	switch codec {
+	case CODEC_NONE:
+		return contents, nil
+	case CODEC_GZIP:
+		reader, err := gzip.NewReader(strings.NewReader(contents))
+
+		if err != nil {
+			return "", err
+		}
+
+		decoded, err := io.ReadAll(reader)
+
+		if err != nil {
+			return "", err
+		}
+
+		return string(decoded), nil
+	}
+
+	return "", errors.New("unsupported codec: " + codec)
+}
@@ -0,0 +1,313 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testCompression gzips the contents, except for the png images
+var testCompression = Compression{
+	Codec:      CODEC_GZIP,
+	Extensions: map[string]string{"png": CODEC_NONE},
+}
+
+func TestContentsEncode(t *testing.T) {
+	contents := strings.Repeat(`{"name": "value"}`, 100)
+
+	encoded, codec, err := contentsEncode(contents, CODEC_GZIP)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if codec != CODEC_GZIP || len(encoded) >= len(contents) {
+		t.Fatal("The contents MUST be compressed, found:", codec, len(encoded))
+	}
+
+	decoded, err := contentsDecode(encoded, codec)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if decoded != contents {
+		t.Fatal("The decoded contents MUST be the contents")
+	}
+
+	// Too short to be made smaller
+	encoded, codec, err = contentsEncode("ab", CODEC_GZIP)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if codec != CODEC_NONE || encoded != "ab" {
+		t.Fatal("The contents not made smaller MUST be kept uncompressed, found:", codec)
+	}
+
+	_, err = contentsDecode("ab", "lz4")
+
+	if err == nil {
+		t.Fatal("An unsupported codec MUST fail")
+	}
+}
+
+func TestStoreCompressionOptions(t *testing.T) {
+	for _, options := range []NewStoreOptions{
+		{Compression: Compression{Codec: "lz4"}},
+		{Compression: Compression{Extensions: map[string]string{"json": "lz4"}}},
+		{Compression: Compression{Codec: CODEC_GZIP}},
+	} {
+		options.DB = initDB(":memory:")
+		options.TableName = "file_compression_options"
+
+		_, err := NewStore(options)
+
+		if err == nil {
+			t.Fatal("The compression MUST be validated:", options.Compression)
+		}
+	}
+}
+
+func TestStoreCompression(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_compression",
+		BinaryContentsEnabled: true,
+		Compression:           testCompression,
+	})
+
+	json := []byte(strings.Repeat(`{"name": "value"}`, 100))
+	png := bytes.Repeat([]byte{0x89, 0x50, 0x4E, 0x47}, 100)
+
+	err := store.WriteFile("/data.json", json)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/logo.png", png)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Too short to be made smaller
+	err = store.WriteFile("/short.txt", []byte("ab"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.tableName, "codec = 'gzip' AND name = 'data.json'") != 1 {
+		t.Fatal("The contents MUST be compressed")
+	}
+
+	if tableCount(t, store, store.tableName, "codec = 'gzip'") != 1 {
+		t.Fatal("The contents of the excluded extensions MUST NOT be compressed")
+	}
+
+	if tableCount(t, store, store.tableName, "name = 'data.json' AND LENGTH(contents) < 100") != 1 {
+		t.Fatal("The compressed contents MUST be smaller")
+	}
+
+	for filePath, expected := range map[string][]byte{"/data.json": json, "/logo.png": png, "/short.txt": []byte("ab")} {
+		contents, err := store.ReadFile(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if !bytes.Equal(contents, expected) {
+			t.Fatal("The contents MUST be read back decompressed:", filePath)
+		}
+	}
+
+	record, err := store.RecordFindByPath("/data.json", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.Size() != "1700" {
+		t.Fatal("The size MUST be the size of the uncompressed contents, found:", record.Size())
+	}
+
+	records, err := store.RecordList(RecordQueryOptions{Type: TYPE_FILE, WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, record := range records {
+		if _, ok := record.Data()[COLUMN_CODEC]; ok {
+			t.Fatal("The codec MUST NOT be exposed:", record.Path())
+		}
+
+		if record.Path() == "/data.json" && record.Contents() != string(json) {
+			t.Fatal("The listed contents MUST be decompressed")
+		}
+	}
+
+	// Rewritten with contents which are not compressed
+	err = store.WriteFile("/data.json", []byte("{}"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.tableName, "codec = 'gzip'") != 0 {
+		t.Fatal("The codec MUST follow the contents")
+	}
+
+	contents, err := store.ReadFile("/data.json")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "{}" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreCompressionMixedRows(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_compression_mixed",
+		BinaryContentsEnabled: true,
+	})
+
+	json := []byte(strings.Repeat(`{"name": "value"}`, 100))
+
+	err := store.WriteFile("/old.json", json)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Compression enabled on an existing store
+	store.compression = Compression{Codec: CODEC_GZIP}
+
+	err = store.WriteFile("/new.json", json)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.tableName, "codec = 'gzip'") != 1 {
+		t.Fatal("Only the new contents MUST be compressed")
+	}
+
+	err = store.Copy("/new.json", "/copy.json", CopyOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, filePath := range []string{"/old.json", "/new.json", "/copy.json"} {
+		contents, err := store.ReadFile(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if !bytes.Equal(contents, json) {
+			t.Fatal("The compressed and uncompressed contents MUST coexist:", filePath)
+		}
+	}
+}
+
+func TestStoreCompressionContentTable(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_compression_content",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+		Compression:           testCompression,
+	})
+	store.EnableVersioning(true)
+
+	json := []byte(strings.Repeat(`{"name": "value"}`, 100))
+
+	for _, filePath := range []string{"/a.json", "/b.json"} {
+		err := store.WriteFile(filePath, json)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if tableCount(t, store, store.contentTableName, "codec = 'gzip' AND ref_count = 4") != 1 {
+		t.Fatal("The shared content row MUST be compressed")
+	}
+
+	contents, err := store.ReadFile("/b.json")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(contents, json) {
+		t.Fatal("The contents of the content table MUST be read back decompressed")
+	}
+
+	record, err := store.RecordFindByPath("/a.json", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	version, err := store.VersionGet(record.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if version == nil || version.Contents() != string(json) {
+		t.Fatal("The version MUST have the decompressed contents:", version)
+	}
+}
+
+func TestStoreCompressionVersions(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_compression_versions",
+		BinaryContentsEnabled: true,
+		Compression:           testCompression,
+	})
+	store.EnableVersioning(true)
+
+	json := []byte(strings.Repeat(`{"name": "value"}`, 100))
+
+	for _, contents := range [][]byte{json, []byte("{}")} {
+		err := store.WriteFile("/data.json", contents)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if tableCount(t, store, store.versionTableName, "codec = 'gzip'") != 1 {
+		t.Fatal("The version MUST keep the codec of its contents")
+	}
+
+	record, err := store.RecordFindByPath("/data.json", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.VersionRestore(record.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/data.json")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(contents, json) {
+		t.Fatal("The restored contents MUST be decompressed")
+	}
+}
@@ -5,6 +5,11 @@ const TYPE_FILE = "file"
 const TYPE_DIRECTORY = "directory"
 const ROOT_PATH = PATH_SEPARATOR
 const ROOT_ID = "0"
+const DEFAULT_CHUNK_SIZE = 64 * 1024
+const DEFAULT_PAGE_SIZE = 100
+
+const CODEC_NONE = ""
+const CODEC_GZIP = "gzip"
 
 const COLUMN_ID = "id"
 const COLUMN_PARENT_ID = "parent_id"
@@ -14,6 +19,21 @@ const COLUMN_TYPE = "type"
 const COLUMN_SIZE = "size"
 const COLUMN_EXTENSION = "extension"
 const COLUMN_CONTENTS = "contents"
+const COLUMN_CONTENT_ID = "content_id"
+const COLUMN_HASH = "hash"
+const COLUMN_CODEC = "codec"
 const COLUMN_CREATED_AT = "created_at"
 const COLUMN_UPDATED_AT = "updated_at"
 const COLUMN_DELETED_AT = "deleted_at"
+
+const COLUMN_RECORD_ID = "record_id"
+const COLUMN_SEQUENCE = "sequence"
+const COLUMN_DATA = "data"
+
+const COLUMN_REF_COUNT = "ref_count"
+
+const COLUMN_AUTHOR = "author"
+const COLUMN_COMMENT = "comment"
+
+const COLUMN_VERSION = "version"
+const COLUMN_APPLIED_AT = "applied_at"
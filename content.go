@@ -0,0 +1,902 @@
+package sqlfilestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+	"github.com/gouniverse/uid"
+	"github.com/samber/lo"
+)
+
+// contentsMigrateBatchSize is the number of records whose contents are
+// moved to the content table in each transaction
+const contentsMigrateBatchSize = 100
+
+// ContentsMigrateToContentTable moves the contents kept in the record table
+// to the content table, for a store created with ContentTableEnabled.
+//
+// The contents are moved in small batches, each in its own short
+// transaction, so that the store remains usable while they are moved.
+// A record changed while its batch is moved is left in place, and moved
+// with a later batch. The records keep being readable throughout, the
+// contents being read from wherever they are.
+//
+// The contents are hashed as they are moved, so that identical contents
+// end up in a single content row.
+func (store *Store) ContentsMigrateToContentTable() error {
+	if !store.contentTableEnabled {
+		return errors.New("the content table is not enabled")
+	}
+
+	for {
+		moved, err := store.contentsMigrateBatch()
+
+		if err != nil {
+			return err
+		}
+
+		if moved == 0 {
+			return nil
+		}
+	}
+}
+
+// == PRIVATE METHODS ========================================================
+
+// contentsMigrateBatch moves the contents of the next batch of records to
+// the content table, returning the number of records found to be moved
+func (store *Store) contentsMigrateBatch() (int, error) {
+	found := 0
+
+	err := store.transaction(func(txStore *Store) error {
+		sqlStr, params, errSql := goqu.Dialect(txStore.dbDriverName).
+			From(txStore.tableName).
+			Prepared(true).
+			Select(goqu.C(COLUMN_ID)).
+			Where(
+				goqu.C(COLUMN_CONTENT_ID).Eq(""),
+				goqu.Func("LENGTH", goqu.C(COLUMN_CONTENTS)).Gt(0),
+			).
+			Limit(contentsMigrateBatchSize).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := txStore.selectToMapString(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+
+		found = len(rows)
+
+		for _, row := range rows {
+			err = txStore.contentsMigrateRecord(row[COLUMN_ID])
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+// contentsMigrateRecord moves the contents of the record with the ID
+// to the content table, see contentsMove
+func (store *Store) contentsMigrateRecord(id string) error {
+	record, err := store.recordFindByID(id, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_CONTENTS, COLUMN_EXTENSION, COLUMN_HASH},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return nil
+	}
+
+	return store.contentsMove(record)
+}
+
+// contentsMove moves the contents of the record, as read, to the content
+// table, unless they are changed meanwhile. A change is detected by the
+// hash written with the contents, which the update compares, the
+// updated_at of the record being too coarse (one second).
+func (store *Store) contentsMove(record *Record) error {
+	// The records written before the hashing have no hash yet
+	hash := contentsHash(record.Contents())
+
+	contentID, err := store.contentAcquire(hash, record.Contents(), store.contentsCodec(record))
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.tableName).
+		Prepared(true).
+		Set(goqu.Record{
+			COLUMN_CONTENTS:   store.contentsValue(""),
+			COLUMN_CODEC:      CODEC_NONE,
+			COLUMN_CONTENT_ID: contentID,
+			COLUMN_HASH:       hash,
+		}).
+		Where(
+			goqu.C(COLUMN_ID).Eq(record.ID()),
+			goqu.C(COLUMN_CONTENT_ID).Eq(""),
+			goqu.C(COLUMN_HASH).Eq(record.Hash()),
+		).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	result, err := store.executeSql(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if updated > 0 {
+		return nil
+	}
+
+	// The record was changed meanwhile, the reference is given back
+	return store.contentReferencesRemove(contentID, 1)
+}
+
+// hashesBackfill sets the hash of the content rows and of the file
+// records written before the hashing, in batches. The content rows found
+// to keep the same contents are merged into one, their references summed.
+func (store *Store) hashesBackfill() error {
+	for _, batch := range []func() (int, error){store.contentHashesBackfillBatch, store.recordHashesBackfillBatch} {
+		for {
+			found, err := batch()
+
+			if err != nil {
+				return err
+			}
+
+			if found == 0 {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// contentHashesBackfillBatch sets the hash of the next batch of content
+// rows without one, merging a row into the row already keeping the same
+// contents, returning the number of rows found
+func (store *Store) contentHashesBackfillBatch() (int, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.contentTableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_ID), goqu.C(COLUMN_CONTENTS), goqu.C(COLUMN_CODEC), goqu.C(COLUMN_REF_COUNT)).
+		Where(goqu.C(COLUMN_HASH).IsNull()).
+		Limit(contentsMigrateBatchSize).
+		ToSQL()
+
+	if errSql != nil {
+		return 0, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		contents, err := contentsDecode(row[COLUMN_CONTENTS], row[COLUMN_CODEC])
+
+		if err != nil {
+			return 0, err
+		}
+
+		hash := contentsHash(contents)
+
+		existingID, err := store.contentFindByHash(hash)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if existingID == "" {
+			err = store.contentUpdate(row[COLUMN_ID], goqu.Record{COLUMN_HASH: hash})
+		} else {
+			err = store.contentMerge(row[COLUMN_ID], existingID, row[COLUMN_REF_COUNT])
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+// contentMerge moves the references to the content row with the ID to the
+// row keeping the same contents, adding its reference count, and deletes it
+func (store *Store) contentMerge(contentID string, intoContentID string, refCount string) error {
+	references, err := strconv.Atoi(refCount)
+
+	if err != nil {
+		return err
+	}
+
+	for _, tableName := range []string{store.tableName, store.versionTableName} {
+		sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+			Update(tableName).
+			Prepared(true).
+			Set(goqu.Record{COLUMN_CONTENT_ID: intoContentID}).
+			Where(goqu.C(COLUMN_CONTENT_ID).Eq(contentID)).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if store.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = store.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = store.contentUpdate(intoContentID, goqu.Record{
+		COLUMN_REF_COUNT: goqu.L("? + ?", goqu.C(COLUMN_REF_COUNT), references),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Delete(store.contentTableName).
+		Prepared(true).
+		Where(goqu.C(COLUMN_ID).Eq(contentID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// contentUpdate sets the values of the content row with the ID
+func (store *Store) contentUpdate(contentID string, values goqu.Record) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.contentTableName).
+		Prepared(true).
+		Set(values).
+		Where(goqu.C(COLUMN_ID).Eq(contentID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// recordHashesBackfillBatch sets the hash of the next batch of file
+// records without one, soft deleted included, the contents being hashed
+// wherever they are kept, returning the number of records found
+func (store *Store) recordHashesBackfillBatch() (int, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.tableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_ID)).
+		Where(
+			goqu.C(COLUMN_TYPE).Eq(TYPE_FILE),
+			goqu.Or(goqu.C(COLUMN_HASH).Eq(""), goqu.C(COLUMN_HASH).IsNull()),
+		).
+		Limit(contentsMigrateBatchSize).
+		ToSQL()
+
+	if errSql != nil {
+		return 0, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		hash, err := store.recordContentsHash(row[COLUMN_ID])
+
+		if err != nil {
+			return 0, err
+		}
+
+		err = store.recordUpdateValues(row[COLUMN_ID], goqu.Record{COLUMN_HASH: hash})
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+// recordContentsHash returns the hash of the contents of the record with
+// the ID, read from its chunks if it has been streamed
+func (store *Store) recordContentsHash(id string) (string, error) {
+	chunks, err := store.chunkList(id)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(chunks) > 0 {
+		hash := sha256.New()
+
+		_, err = io.Copy(hash, newChunkReader(store, id, chunks))
+
+		if err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	record, err := store.recordFindByID(id, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_CONTENTS},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if record == nil {
+		return "", wrapError(ErrNotFound, "record not found: "+id)
+	}
+
+	return contentsHash(record.Contents()), nil
+}
+
+// contentsSeparate moves the contents of the record values to be written
+// into the content row with the hash of the contents, referenced by its
+// content ID, when the content table is enabled. Otherwise the contents
+// stay in the values, compressed with the codec, and the record
+// references no content row.
+func (store *Store) contentsSeparate(values goqu.Record, contents string, hash string, codec string) error {
+	values[COLUMN_CONTENT_ID] = ""
+
+	if !store.contentTableEnabled || contents == "" {
+		encoded, usedCodec, err := contentsEncode(contents, codec)
+
+		if err != nil {
+			return err
+		}
+
+		values[COLUMN_CONTENTS] = store.contentsValue(encoded)
+		values[COLUMN_CODEC] = usedCodec
+
+		return nil
+	}
+
+	// The contents are never keyed by an empty hash
+	if hash == "" {
+		hash = contentsHash(contents)
+	}
+
+	contentID, err := store.contentAcquire(hash, contents, codec)
+
+	if err != nil {
+		return err
+	}
+
+	values[COLUMN_CONTENTS] = store.contentsValue("")
+	values[COLUMN_CODEC] = CODEC_NONE
+	values[COLUMN_CONTENT_ID] = contentID
+
+	return nil
+}
+
+// contentAcquire returns the ID of the content row with the hash, adding
+// a reference to it, or inserts the row with a single reference if the
+// contents are not kept yet, compressed with the codec
+func (store *Store) contentAcquire(hash string, contents string, codec string) (string, error) {
+	// A locking read of a missing hash takes a gap lock on MySQL, on which
+	// two transactions inserting the same contents would deadlock
+	if store.dbDriverName == sb.DIALECT_MYSQL {
+		return store.contentUpsert(hash, contents, codec)
+	}
+
+	// Only new contents are compressed, once
+	encoded, usedCodec := "", ""
+
+	// A second attempt finds the row inserted concurrently
+	for attempt := 0; attempt < 2; attempt++ {
+		contentID, err := store.contentFindByHash(hash)
+
+		if err != nil {
+			return "", err
+		}
+
+		if contentID != "" {
+			return contentID, store.contentReferencesAdd(contentID)
+		}
+
+		if attempt == 0 {
+			encoded, usedCodec, err = contentsEncode(contents, codec)
+
+			if err != nil {
+				return "", err
+			}
+		}
+
+		contentID = uid.HumanUid()
+
+		// A row inserted concurrently with the same hash
+		// is found by the second attempt
+		sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+			Insert(store.contentTableName).
+			Prepared(true).
+			Rows(store.contentRow(contentID, hash, encoded, usedCodec)).
+			OnConflict(goqu.DoNothing()).
+			ToSQL()
+
+		if errSql != nil {
+			return "", errSql
+		}
+
+		if store.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		result, err := store.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return "", err
+		}
+
+		inserted, err := result.RowsAffected()
+
+		if err != nil {
+			return "", err
+		}
+
+		if inserted > 0 {
+			return contentID, nil
+		}
+	}
+
+	return "", errors.New("the contents could not be stored: " + hash)
+}
+
+// contentUpsert inserts the content row with the hash, or adds a reference
+// to the existing row in the same statement, returning its ID. It is used
+// on MySQL, where the statement locks the row without a gap lock.
+func (store *Store) contentUpsert(hash string, contents string, codec string) (string, error) {
+	encoded, usedCodec, err := contentsEncode(contents, codec)
+
+	if err != nil {
+		return "", err
+	}
+
+	contentID := uid.HumanUid()
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Insert(store.contentTableName).
+		Prepared(true).
+		Rows(store.contentRow(contentID, hash, encoded, usedCodec)).
+		ToSQL()
+
+	if errSql != nil {
+		return "", errSql
+	}
+
+	// Written out, as goqu renders the clause with INSERT IGNORE,
+	// which would turn the other errors into warnings
+	sqlStr += " ON DUPLICATE KEY UPDATE `" + COLUMN_REF_COUNT + "` = `" + COLUMN_REF_COUNT + "` + 1"
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	result, err := store.executeSql(sqlStr, params...)
+
+	if err != nil {
+		return "", err
+	}
+
+	// MySQL reports one row affected by an insert, two by an update
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return "", err
+	}
+
+	if affected == 1 {
+		return contentID, nil
+	}
+
+	existingID, err := store.contentFindByHash(hash)
+
+	if err != nil {
+		return "", err
+	}
+
+	if existingID == "" {
+		return "", errors.New("the contents could not be stored: " + hash)
+	}
+
+	return existingID, nil
+}
+
+// contentRow returns the values of a new content row, with a single reference
+func (store *Store) contentRow(contentID string, hash string, encoded string, codec string) goqu.Record {
+	return goqu.Record{
+		COLUMN_ID:         contentID,
+		COLUMN_HASH:       hash,
+		COLUMN_CONTENTS:   store.contentsValue(encoded),
+		COLUMN_CODEC:      codec,
+		COLUMN_REF_COUNT:  1,
+		COLUMN_CREATED_AT: carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+	}
+}
+
+// contentFindByHash returns the ID of the content row with the hash,
+// empty if there is none
+func (store *Store) contentFindByHash(hash string) (string, error) {
+	q := goqu.Dialect(store.dbDriverName).
+		From(store.contentTableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_ID)).
+		Where(goqu.C(COLUMN_HASH).Eq(hash)).
+		Limit(1)
+
+	if store.lockingReadsSupported() {
+		q = q.ForUpdate(exp.Wait)
+	}
+
+	sqlStr, params, errSql := q.ToSQL()
+
+	if errSql != nil {
+		return "", errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(rows) == 0 {
+		return "", nil
+	}
+
+	return rows[0][COLUMN_ID], nil
+}
+
+// contentsCopy adds a reference to the content row of the record with the
+// ID, if any, so that the copy shares it, returning its ID, empty if the
+// record has no content row
+func (store *Store) contentsCopy(recordID string) (string, error) {
+	record, err := store.recordFindByID(recordID, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_CONTENT_ID},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if record == nil || record.ContentID() == "" {
+		return "", nil
+	}
+
+	return record.ContentID(), store.contentReferencesAdd(record.ContentID())
+}
+
+// contentsRelease removes the references of the rows of the table (the
+// records or their versions) matching the condition to their content
+// rows, deleting the content rows no longer referenced
+func (store *Store) contentsRelease(tableName string, where exp.Expression) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(tableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_CONTENT_ID)).
+		Where(where, goqu.C(COLUMN_CONTENT_ID).Neq("")).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	references := lo.CountValuesBy(rows, func(row map[string]string) string {
+		return row[COLUMN_CONTENT_ID]
+	})
+
+	// In a stable order, so that concurrent releases lock the rows alike
+	contentIDs := lo.Keys(references)
+	slices.Sort(contentIDs)
+
+	for _, contentID := range contentIDs {
+		err = store.contentReferencesRemove(contentID, references[contentID])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// contentReferencesAdd adds a reference to the content row with the ID
+func (store *Store) contentReferencesAdd(contentID string) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.contentTableName).
+		Prepared(true).
+		Set(goqu.Record{COLUMN_REF_COUNT: goqu.L("? + 1", goqu.C(COLUMN_REF_COUNT))}).
+		Where(goqu.C(COLUMN_ID).Eq(contentID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// contentReferencesRemove removes the number of references to the content
+// row with the ID, deleting the row once it is no longer referenced
+func (store *Store) contentReferencesRemove(contentID string, count int) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.contentTableName).
+		Prepared(true).
+		Set(goqu.Record{COLUMN_REF_COUNT: goqu.L("? - ?", goqu.C(COLUMN_REF_COUNT), count)}).
+		Where(goqu.C(COLUMN_ID).Eq(contentID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql = goqu.Dialect(store.dbDriverName).
+		Delete(store.contentTableName).
+		Prepared(true).
+		Where(
+			goqu.C(COLUMN_ID).Eq(contentID),
+			goqu.C(COLUMN_REF_COUNT).Lte(0),
+		).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// contentsLoad sets the contents of the records which reference a content
+// row, read from the content table with a single query, and decompresses
+// the contents kept in the records. The codec is a detail of the storage,
+// removed from the records.
+func (store *Store) contentsLoad(records []Record) error {
+	for i := range records {
+		data := records[i].Data()
+		codec, ok := data[COLUMN_CODEC]
+
+		if !ok {
+			continue
+		}
+
+		delete(data, COLUMN_CODEC)
+
+		if records[i].ContentID() != "" {
+			continue
+		}
+
+		contents, err := contentsDecode(data[COLUMN_CONTENTS], codec)
+
+		if err != nil {
+			return err
+		}
+
+		records[i].contentsHydrate(contents)
+	}
+
+	contentIDs := lo.Uniq(lo.FilterMap(records, func(record Record, _ int) (string, bool) {
+		return record.ContentID(), record.ContentID() != ""
+	}))
+
+	if len(contentIDs) == 0 {
+		return nil
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.contentTableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_ID), goqu.C(COLUMN_CONTENTS), goqu.C(COLUMN_CODEC)).
+		Where(goqu.C(COLUMN_ID).In(contentIDs)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	contents := map[string]string{}
+
+	for _, row := range rows {
+		decoded, err := contentsDecode(row[COLUMN_CONTENTS], row[COLUMN_CODEC])
+
+		if err != nil {
+			return err
+		}
+
+		contents[row[COLUMN_ID]] = decoded
+	}
+
+	for i := range records {
+		if contentID := records[i].ContentID(); contentID != "" {
+			records[i].contentsHydrate(contents[contentID])
+		}
+	}
+
+	return nil
+}
+
+// contentsColumns returns the columns to select, with the content ID and
+// the codec when the contents are selected, so that they can be loaded
+func contentsColumns(columns []string) []string {
+	if !slices.Contains(columns, COLUMN_CONTENTS) {
+		return columns
+	}
+
+	return lo.Uniq(append(slices.Clone(columns), COLUMN_CONTENT_ID, COLUMN_CODEC))
+}
+
+// contentsCodec returns the codec the contents of the record are
+// compressed with, by the extension of the record, or of its path
+func (store *Store) contentsCodec(record *Record) string {
+	extension := record.Extension()
+
+	if extension == "" {
+		extension = pathExtension(record.Path())
+	}
+
+	return store.compression.codec(extension)
+}
+
+// contentsHash returns the hex encoded SHA-256 of the contents
+func contentsHash(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashOnWrite sets the hash of the record to the hash of the contents
+// being written with the data (the data of a new record, or the changed
+// data), or to the given hash, if not empty, as for a streamed write
+func hashOnWrite(record *Record, data map[string]string, hash string) {
+	contents, ok := data[COLUMN_CONTENTS]
+
+	if !ok || record.IsDirectory() {
+		return
+	}
+
+	if hash == "" {
+		hash = contentsHash(contents)
+	}
+
+	record.SetHash(hash)
+}
+
+// contentsValue returns the value the contents are written with,
+// as bytes if the contents are stored in a binary column
+func (store *Store) contentsValue(contents string) any {
+	if store.binaryContents {
+		return []byte(contents)
+	}
+
+	return contents
+}
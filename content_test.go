@@ -0,0 +1,303 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// tableCount returns the number of rows of the table matching the condition
+func tableCount(t *testing.T, store *Store, tableName string, where string) int {
+	rows, err := store.selectToMapString(`SELECT COUNT(*) AS count FROM "` + tableName + `" WHERE ` + where)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := strconv.Atoi(rows[0]["count"])
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return count
+}
+
+func TestStoreContentTable(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_content_table",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+	})
+
+	contentTableName := store.contentTableName
+	data := []byte{0x89, 0x50, 0x4E, 0x47, 0x00, 0xFF, 0xFE}
+
+	err := store.MkdirAll("/docs")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/image.png", data)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.tableName, "LENGTH(contents) > 0") != 0 {
+		t.Fatal("The record table MUST NOT keep the contents")
+	}
+
+	if tableCount(t, store, contentTableName, "1 = 1") != 1 {
+		t.Fatal("The content table MUST keep the contents")
+	}
+
+	contents, err := store.ReadFile("/docs/image.png")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(contents, data) {
+		t.Fatal("expected:", data, "found:", contents)
+	}
+
+	records, err := store.RecordList(RecordQueryOptions{Type: TYPE_FILE, WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(records) != 1 || !bytes.Equal(records[0].ContentsBytes(), data) || records[0].ContentID() == "" {
+		t.Fatal("The contents MUST be listed with WithContents:", records)
+	}
+
+	// The previous content row is replaced
+	err = store.WriteFile("/docs/image.png", []byte("UPDATED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "1 = 1") != 1 {
+		t.Fatal("The previous contents MUST be deleted")
+	}
+
+	// A copy shares the content row
+	err = store.Copy("/docs", "/copy", CopyOptions{Recursive: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "ref_count = 2") != 1 {
+		t.Fatal("The copied contents MUST share the content row")
+	}
+
+	copied, err := store.RecordFindByPath("/copy/image.png", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordLoadContents(copied)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if copied.Contents() != "UPDATED" {
+		t.Fatal("expected the copied contents, found:", copied.Contents())
+	}
+
+	err = store.Remove("/docs/image.png")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	copyDir, err := store.RecordFindByPath("/copy", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDeleteRecursive(copyDir)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "1 = 1") != 0 {
+		t.Fatal("The contents of the deleted records MUST be deleted")
+	}
+}
+
+func TestStoreContentsMigrateToContentTable(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_contents_migrate",
+		BinaryContentsEnabled: true,
+	})
+
+	// More than a batch
+	for i := 0; i < contentsMigrateBatchSize+20; i++ {
+		err := store.WriteFile(fmt.Sprintf("/file%03d.txt", i), []byte(fmt.Sprint("FILE ", i)))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	err := store.ContentsMigrateToContentTable()
+
+	if err == nil {
+		t.Fatal("The migration MUST require the content table to be enabled")
+	}
+
+	// A record changed since it was read, within the same second,
+	// is left in place
+	changed, err := store.RecordFindByPath("/file000.txt", RecordQueryOptions{
+		Columns: []string{COLUMN_ID, COLUMN_CONTENTS, COLUMN_EXTENSION, COLUMN_HASH},
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/file000.txt", []byte("CHANGED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store.contentTableEnabled = true
+
+	err = store.contentsMove(changed)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.contentTableName, "1 = 1") != 0 {
+		t.Fatal("The contents of a changed record MUST NOT be moved")
+	}
+
+	if tableCount(t, store, store.tableName, "name = 'file000.txt' AND content_id = ''") != 1 {
+		t.Fatal("The changed record MUST keep its contents")
+	}
+
+	err = store.WriteFile("/file000.txt", []byte("FILE 0"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.ContentsMigrateToContentTable()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.tableName, "LENGTH(contents) > 0") != 0 {
+		t.Fatal("All the contents MUST be moved")
+	}
+
+	if tableCount(t, store, store.contentTableName, "1 = 1") != contentsMigrateBatchSize+20 {
+		t.Fatal("The content table MUST keep all the contents")
+	}
+
+	for _, i := range []int{0, 42, contentsMigrateBatchSize + 19} {
+		contents, err := store.ReadFile(fmt.Sprintf("/file%03d.txt", i))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if string(contents) != fmt.Sprint("FILE ", i) {
+			t.Fatal("unexpected contents:", string(contents))
+		}
+	}
+}
+
+func TestStoreHashesBackfill(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_hashes_backfill",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+	})
+
+	for filePath, contents := range map[string]string{"/a.txt": "same", "/b.txt": "other"} {
+		err := store.WriteFile(filePath, []byte(contents))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	columns := []string{COLUMN_ID, COLUMN_CONTENT_ID}
+
+	a, err := store.RecordFindByPath("/a.txt", RecordQueryOptions{Columns: columns})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	b, err := store.RecordFindByPath("/b.txt", RecordQueryOptions{Columns: columns})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The rows of a store written before the hashing, two of them
+	// keeping the same contents
+	for _, sqlStr := range []string{
+		`UPDATE "file_hashes_backfill_content" SET "contents" = (SELECT "contents" FROM "file_hashes_backfill_content" WHERE "id" = '` + a.ContentID() + `') WHERE "id" = '` + b.ContentID() + `'`,
+		`UPDATE "file_hashes_backfill_content" SET "hash" = NULL`,
+		`UPDATE "file_hashes_backfill" SET "hash" = ''`,
+		`DELETE FROM "file_hashes_backfill_schema" WHERE "version" = 9`,
+	} {
+		_, err = store.executeSql(sqlStr)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	err = store.AutoMigrate()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.contentTableName, `"hash" IS NULL`) != 0 {
+		t.Fatal("The hashes of the content rows MUST be backfilled")
+	}
+
+	if tableCount(t, store, store.contentTableName, "1 = 1") != 1 {
+		t.Fatal("The content rows with the same contents MUST be merged")
+	}
+
+	if tableCount(t, store, store.contentTableName, "ref_count = 2") != 1 {
+		t.Fatal("The references of the merged content rows MUST be summed")
+	}
+
+	if tableCount(t, store, store.tableName, "hash = '"+contentsHash("same")+"' AND content_id = (SELECT id FROM file_hashes_backfill_content)") != 2 {
+		t.Fatal("The records MUST reference the merged content row, with its hash")
+	}
+
+	if tableCount(t, store, store.tableName, "type = 'file' AND hash = ''") != 0 {
+		t.Fatal("The hashes of the records MUST be backfilled")
+	}
+
+	contents, err := store.ReadFile("/b.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "same" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
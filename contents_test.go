@@ -0,0 +1,115 @@
+package sqlfilestore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStoreRecordLoadContents(t *testing.T) {
+	testRecordLoadContents(t, initTxStore(t, "file_load_contents"))
+}
+
+func TestMemoryStoreRecordLoadContents(t *testing.T) {
+	testRecordLoadContents(t, NewMemoryStore())
+}
+
+func testRecordLoadContents(t *testing.T, store StoreInterface) {
+	err := store.WriteFile("/report.txt", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	writer, err := store.CreateWriter("/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte(strings.Repeat("STREAMED", 10)))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The contents are not loaded by default
+	record, err := store.RecordFindByPath("/report.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, loaded := record.Data()[COLUMN_CONTENTS]; loaded || record.Size() != "6" {
+		t.Fatal("Only the metadata MUST be loaded:", record.Data())
+	}
+
+	records, err := store.RecordList(RecordQueryOptions{Type: TYPE_FILE})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, listed := range records {
+		if _, loaded := listed.Data()[COLUMN_CONTENTS]; loaded || listed.Path() == "" {
+			t.Fatal("Only the metadata MUST be listed:", listed.Data())
+		}
+	}
+
+	withContents, err := store.RecordFindByPath("/report.txt", RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if withContents.Contents() != "REPORT" {
+		t.Fatal("The contents MUST be loaded with WithContents, found:", withContents.Contents())
+	}
+
+	metadata := record.Data()
+
+	err = store.RecordLoadContents(record)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, loaded := metadata[COLUMN_CONTENTS]; loaded {
+		t.Fatal("The data returned before MUST NOT be changed by loading the contents")
+	}
+
+	if record.Contents() != "REPORT" {
+		t.Fatal("expected the contents, found:", record.Contents())
+	}
+
+	if record.IsDirty() {
+		t.Fatal("Loading the contents MUST NOT mark the record as changed:", record.DataChanged())
+	}
+
+	streamed, err := store.RecordFindByPath("/streamed.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordLoadContents(streamed)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if streamed.Contents() != strings.Repeat("STREAMED", 10) {
+		t.Fatal("The streamed contents MUST be loaded, found:", streamed.Contents())
+	}
+
+	err = store.RecordLoadContents(NewFile())
+
+	if err == nil {
+		t.Fatal("Loading the contents of a missing record MUST fail")
+	}
+}
@@ -0,0 +1,266 @@
+package sqlfilestore
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// This file contains the context aware variants of the store methods.
+// Each variant runs the method on a copy of the store bound to the context,
+// so that every statement executed by the method, including those of
+// recursive operations and transactions, is cancelled with the context.
+
+// AutoMigrateWithContext is AutoMigrate, with a context
+func (store *Store) AutoMigrateWithContext(ctx context.Context) error {
+	return store.withContext(ctx).AutoMigrate()
+}
+
+// ContentsMigrateToBinaryWithContext is ContentsMigrateToBinary, with a context
+func (store *Store) ContentsMigrateToBinaryWithContext(ctx context.Context) error {
+	return store.withContext(ctx).ContentsMigrateToBinary()
+}
+
+// ContentsMigrateToContentTableWithContext is ContentsMigrateToContentTable,
+// with a context
+func (store *Store) ContentsMigrateToContentTableWithContext(ctx context.Context) error {
+	return store.withContext(ctx).ContentsMigrateToContentTable()
+}
+
+// CopyWithContext is Copy, with a context
+func (store *Store) CopyWithContext(ctx context.Context, srcPath string, dstPath string, options CopyOptions) error {
+	return store.withContext(ctx).Copy(srcPath, dstPath, options)
+}
+
+// CreateWithContext is Create, with a context
+func (store *Store) CreateWithContext(ctx context.Context, filePath string) (*Record, error) {
+	return store.withContext(ctx).Create(filePath)
+}
+
+// CreateWriterWithContext is CreateWriter, with a context.
+// The context is used until the writer is closed.
+func (store *Store) CreateWriterWithContext(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	return store.withContext(ctx).CreateWriter(filePath)
+}
+
+// DirectoryEnsureWithContext is DirectoryEnsure, with a context
+func (store *Store) DirectoryEnsureWithContext(ctx context.Context, dirPath string) (*Record, error) {
+	return store.withContext(ctx).DirectoryEnsure(dirPath)
+}
+
+// GlobWithContext is Glob, with a context
+func (store *Store) GlobWithContext(ctx context.Context, pattern string) ([]Record, error) {
+	return store.withContext(ctx).Glob(pattern)
+}
+
+// ListPageWithContext is ListPage, with a context
+func (store *Store) ListPageWithContext(ctx context.Context, options RecordQueryOptions, cursor string) ([]Record, string, error) {
+	return store.withContext(ctx).ListPage(options, cursor)
+}
+
+// ListRecursiveWithContext is ListRecursive, with a context
+func (store *Store) ListRecursiveWithContext(ctx context.Context, root string, options ListOptions) ([]Record, error) {
+	return store.withContext(ctx).ListRecursive(root, options)
+}
+
+// MigrationsPendingWithContext is MigrationsPending, with a context
+func (store *Store) MigrationsPendingWithContext(ctx context.Context) ([]Migration, error) {
+	return store.withContext(ctx).MigrationsPending()
+}
+
+// MkdirWithContext is Mkdir, with a context
+func (store *Store) MkdirWithContext(ctx context.Context, dirPath string) error {
+	return store.withContext(ctx).Mkdir(dirPath)
+}
+
+// MkdirAllWithContext is MkdirAll, with a context
+func (store *Store) MkdirAllWithContext(ctx context.Context, dirPath string) error {
+	return store.withContext(ctx).MkdirAll(dirPath)
+}
+
+// MoveWithContext is Move, with a context
+func (store *Store) MoveWithContext(ctx context.Context, id string, newParentID string, newName string) error {
+	return store.withContext(ctx).Move(id, newParentID, newName)
+}
+
+// OpenWithContext is Open, with a context.
+// The context is used until the reader is closed.
+func (store *Store) OpenWithContext(ctx context.Context, filePath string) (io.ReadSeekCloser, error) {
+	return store.withContext(ctx).Open(filePath)
+}
+
+// OpenFileWithContext is OpenFile, with a context
+func (store *Store) OpenFileWithContext(ctx context.Context, filePath string, flag int) (*Record, error) {
+	return store.withContext(ctx).OpenFile(filePath, flag)
+}
+
+// ReadFileWithContext is ReadFile, with a context
+func (store *Store) ReadFileWithContext(ctx context.Context, filePath string) ([]byte, error) {
+	return store.withContext(ctx).ReadFile(filePath)
+}
+
+// RecordCountWithContext is RecordCount, with a context
+func (store *Store) RecordCountWithContext(ctx context.Context, options RecordQueryOptions) (int64, error) {
+	return store.withContext(ctx).RecordCount(options)
+}
+
+// RecordCreateWithContext is RecordCreate, with a context
+func (store *Store) RecordCreateWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordCreate(record)
+}
+
+// RecordDeleteWithContext is RecordDelete, with a context
+func (store *Store) RecordDeleteWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordDelete(record)
+}
+
+// RecordDeleteByIDWithContext is RecordDeleteByID, with a context
+func (store *Store) RecordDeleteByIDWithContext(ctx context.Context, id string) error {
+	return store.withContext(ctx).RecordDeleteByID(id)
+}
+
+// RecordDeleteRecursiveWithContext is RecordDeleteRecursive, with a context
+func (store *Store) RecordDeleteRecursiveWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordDeleteRecursive(record)
+}
+
+// RecordFindByIDWithContext is RecordFindByID, with a context
+func (store *Store) RecordFindByIDWithContext(ctx context.Context, id string, options RecordQueryOptions) (*Record, error) {
+	return store.withContext(ctx).RecordFindByID(id, options)
+}
+
+// RecordFindByPathWithContext is RecordFindByPath, with a context
+func (store *Store) RecordFindByPathWithContext(ctx context.Context, path string, options RecordQueryOptions) (*Record, error) {
+	return store.withContext(ctx).RecordFindByPath(path, options)
+}
+
+// RecordListWithContext is RecordList, with a context
+func (store *Store) RecordListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error) {
+	return store.withContext(ctx).RecordList(options)
+}
+
+// RecordLoadContentsWithContext is RecordLoadContents, with a context
+func (store *Store) RecordLoadContentsWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordLoadContents(record)
+}
+
+// RecordRecalculatePathWithContext is RecordRecalculatePath, with a context.
+// Cancelling the context rolls back all the paths recalculated so far.
+func (store *Store) RecordRecalculatePathWithContext(ctx context.Context, record *Record, parentRecord *Record) error {
+	return store.withContext(ctx).RecordRecalculatePath(record, parentRecord)
+}
+
+// RecordRestoreWithContext is RecordRestore, with a context
+func (store *Store) RecordRestoreWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordRestore(record)
+}
+
+// RecordRestoreByIDWithContext is RecordRestoreByID, with a context
+func (store *Store) RecordRestoreByIDWithContext(ctx context.Context, id string) error {
+	return store.withContext(ctx).RecordRestoreByID(id)
+}
+
+// RecordSoftDeleteWithContext is RecordSoftDelete, with a context
+func (store *Store) RecordSoftDeleteWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordSoftDelete(record)
+}
+
+// RecordSoftDeleteByIDWithContext is RecordSoftDeleteByID, with a context
+func (store *Store) RecordSoftDeleteByIDWithContext(ctx context.Context, id string) error {
+	return store.withContext(ctx).RecordSoftDeleteByID(id)
+}
+
+// RecordSoftDeleteRecursiveWithContext is RecordSoftDeleteRecursive, with a context
+func (store *Store) RecordSoftDeleteRecursiveWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordSoftDeleteRecursive(record)
+}
+
+// RecordUpdateWithContext is RecordUpdate, with a context
+func (store *Store) RecordUpdateWithContext(ctx context.Context, record *Record) error {
+	return store.withContext(ctx).RecordUpdate(record)
+}
+
+// RecordUpdateVersionedWithContext is RecordUpdateVersioned, with a context
+func (store *Store) RecordUpdateVersionedWithContext(ctx context.Context, record *Record, options VersionOptions) error {
+	return store.withContext(ctx).RecordUpdateVersioned(record, options)
+}
+
+// RemoveWithContext is Remove, with a context
+func (store *Store) RemoveWithContext(ctx context.Context, filePath string) error {
+	return store.withContext(ctx).Remove(filePath)
+}
+
+// RemoveAllWithContext is RemoveAll, with a context
+func (store *Store) RemoveAllWithContext(ctx context.Context, filePath string) error {
+	return store.withContext(ctx).RemoveAll(filePath)
+}
+
+// RenameWithContext is Rename, with a context
+func (store *Store) RenameWithContext(ctx context.Context, oldPath string, newPath string) error {
+	return store.withContext(ctx).Rename(oldPath, newPath)
+}
+
+// StatWithContext is Stat, with a context
+func (store *Store) StatWithContext(ctx context.Context, filePath string) (fs.FileInfo, error) {
+	return store.withContext(ctx).Stat(filePath)
+}
+
+// TrashListWithContext is TrashList, with a context
+func (store *Store) TrashListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error) {
+	return store.withContext(ctx).TrashList(options)
+}
+
+// TrashPurgeWithContext is TrashPurge, with a context
+func (store *Store) TrashPurgeWithContext(ctx context.Context, olderThan time.Duration) (int, error) {
+	return store.withContext(ctx).TrashPurge(olderThan)
+}
+
+// VersionGetWithContext is VersionGet, with a context
+func (store *Store) VersionGetWithContext(ctx context.Context, id string, number int) (*Version, error) {
+	return store.withContext(ctx).VersionGet(id, number)
+}
+
+// VersionListWithContext is VersionList, with a context
+func (store *Store) VersionListWithContext(ctx context.Context, id string) ([]Version, error) {
+	return store.withContext(ctx).VersionList(id)
+}
+
+// VersionPurgeWithContext is VersionPurge, with a context
+func (store *Store) VersionPurgeWithContext(ctx context.Context) (int, error) {
+	return store.withContext(ctx).VersionPurge()
+}
+
+// VersionRestoreWithContext is VersionRestore, with a context
+func (store *Store) VersionRestoreWithContext(ctx context.Context, id string, number int) error {
+	return store.withContext(ctx).VersionRestore(id, number)
+}
+
+// WalkWithContext is Walk, with a context
+func (store *Store) WalkWithContext(ctx context.Context, root string, fn WalkFunc) error {
+	return store.withContext(ctx).Walk(root, fn)
+}
+
+// WriteFileWithContext is WriteFile, with a context
+func (store *Store) WriteFileWithContext(ctx context.Context, filePath string, data []byte) error {
+	return store.withContext(ctx).WriteFile(filePath, data)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// withContext returns a copy of the store bound to the context
+func (store *Store) withContext(ctx context.Context) *Store {
+	ctxStore := *store
+	ctxStore.ctx = ctx
+	return &ctxStore
+}
+
+// contextOrBackground returns the context the store is bound to,
+// or the background context if there is none
+func (store *Store) contextOrBackground() context.Context {
+	if store.ctx != nil {
+		return store.ctx
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,110 @@
+package sqlfilestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStoreWithContext(t *testing.T) {
+	store := initTxStore(t, "file_with_context")
+
+	ctx := context.Background()
+
+	err := store.MkdirAllWithContext(ctx, "/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFileWithContext(ctx, "/docs/2026/report.txt", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPathWithContext(ctx, "/docs/2026/report.txt", RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil || record.Contents() != "REPORT" {
+		t.Fatal("unexpected record:", record)
+	}
+
+	if store.ctx != nil {
+		t.Fatal("Store MUST NOT be bound to the context")
+	}
+}
+
+func TestStoreWithCancelledContext(t *testing.T) {
+	store := initTxStore(t, "file_with_cancelled_context")
+
+	err := store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = store.RecordListWithContext(ctx, RecordQueryOptions{})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, found:", err)
+	}
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	docs.SetName("documents")
+
+	err = store.RecordRecalculatePathWithContext(ctx, docs, nil)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, found:", err)
+	}
+
+	_, err = store.Stat("/docs/2026")
+
+	if err != nil {
+		t.Fatal("Paths MUST NOT be recalculated:", err)
+	}
+}
+
+func TestStoreNilContext(t *testing.T) {
+	testNilContext(t, initTxStore(t, "file_nil_context"))
+}
+
+func TestMemoryStoreNilContext(t *testing.T) {
+	testNilContext(t, NewMemoryStore())
+}
+
+// testNilContext checks that a nil context is taken as the background
+// context, the same by both stores
+func testNilContext(t *testing.T, store StoreInterface) {
+	var ctx context.Context
+
+	err := store.Transaction(ctx, func(tx StoreInterface) error {
+		return tx.WriteFile("/readme.txt", []byte("README"))
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	records, err := store.RecordListWithContext(ctx, RecordQueryOptions{Type: TYPE_FILE})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(records) != 1 || records[0].Path() != "/readme.txt" {
+		t.Fatal("The file MUST be written in the transaction:", records)
+	}
+}
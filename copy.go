@@ -0,0 +1,334 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+	"github.com/gouniverse/uid"
+)
+
+// CopyOptions define the options for copying files and directories
+type CopyOptions struct {
+	// Recursive must be set to copy a directory with everything it contains
+	Recursive bool
+
+	// Overwrite replaces an existing file or directory at the destination
+	Overwrite bool
+}
+
+// Copy copies the file or directory at the source path to the destination
+// path. The copies get new IDs, their parent IDs and paths are set to the new
+// location, while the type, extension, size and contents are preserved.
+// Soft deleted records are not copied.
+//
+// The destination parent directory must exist. Everything is copied in
+// a single transaction, so that a partial copy is never visible.
+func (store *Store) Copy(srcPath string, dstPath string, options CopyOptions) error {
+	return operations{store}.copy(srcPath, dstPath, options)
+}
+
+// Copy copies the file or directory at the source path to the destination
+// path, see Store.Copy
+func (store *MemoryStore) Copy(srcPath string, dstPath string, options CopyOptions) error {
+	return operations{store}.copy(srcPath, dstPath, options)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// copy implements Copy
+func (store operations) copy(srcPath string, dstPath string, options CopyOptions) error {
+	srcPath, errPath := store.cleanPath("copy", srcPath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	dstPath, errPath = store.cleanPath("copy", dstPath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	if dstPath == ROOT_PATH {
+		return &fs.PathError{Op: "copy", Path: dstPath, Err: fs.ErrPermission}
+	}
+
+	if srcPath == dstPath || strings.HasPrefix(dstPath, strings.TrimSuffix(srcPath, PATH_SEPARATOR)+PATH_SEPARATOR) {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: errors.New("cannot copy a directory into itself")}
+	}
+
+	err := store.transaction(func(txStore operations) error {
+		return txStore.treeCopy(srcPath, dstPath, options)
+	})
+
+	var pathErr *fs.PathError
+	if err != nil && !errors.As(err, &pathErr) {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: err}
+	}
+
+	return err
+}
+
+// treeCopy copies the record at the source path, with its descendants for
+// a directory, and must be called in a transaction
+func (store operations) treeCopy(srcPath string, dstPath string, options CopyOptions) error {
+	source, err := store.recordFindByPath(srcPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return err
+	}
+
+	if source == nil {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: ErrNotFound}
+	}
+
+	if source.IsDirectory() && !options.Recursive {
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: errors.New("is a directory (not copied)")}
+	}
+
+	parent, err := store.parentDirectory("copy", dstPath)
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := store.recordFindByPath(dstPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return err
+	}
+
+	if existing != nil && !options.Overwrite && store.settings().autoSuffixEnabled {
+		target := NewRecordFromExistingData(map[string]string{
+			COLUMN_PARENT_ID:  parent.ID(),
+			COLUMN_NAME:       path.Base(dstPath),
+			COLUMN_PATH:       dstPath,
+			COLUMN_DELETED_AT: sb.NULL_DATETIME,
+		})
+
+		err = store.recordEnsureUnique(target, true)
+
+		if err != nil {
+			return err
+		}
+
+		dstPath = target.Path()
+		existing = nil
+	}
+
+	if existing != nil {
+		if !options.Overwrite {
+			return &fs.PathError{Op: "copy", Path: dstPath, Err: ErrExists}
+		}
+
+		if strings.HasPrefix(srcPath, dstPath+PATH_SEPARATOR) {
+			return &fs.PathError{Op: "copy", Path: dstPath, Err: errors.New("cannot overwrite a parent of the source")}
+		}
+
+		err = store.RemoveAll(dstPath)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	records := []Record{*source}
+
+	if source.IsDirectory() {
+		descendants, err := store.RecordList(RecordQueryOptions{
+			PathStartsWith: srcPath + PATH_SEPARATOR,
+			Columns:        fsMetadataColumns,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, descendant := range descendants {
+			if strings.HasPrefix(descendant.Path(), srcPath+PATH_SEPARATOR) {
+				records = append(records, descendant)
+			}
+		}
+
+		// The parents go first, so that their new IDs are known to the children
+		sort.SliceStable(records, func(i, j int) bool {
+			return strings.Count(records[i].Path(), PATH_SEPARATOR) < strings.Count(records[j].Path(), PATH_SEPARATOR)
+		})
+	}
+
+	newIDs := map[string]string{}
+
+	for _, record := range records {
+		newID := uid.HumanUid()
+		newIDs[record.ID()] = newID
+
+		copied := NewRecordFromExistingData(map[string]string{
+			COLUMN_ID:        newID,
+			COLUMN_PARENT_ID: newIDs[record.ParentID()],
+			COLUMN_NAME:      record.Name(),
+			COLUMN_EXTENSION: record.Extension(),
+			COLUMN_PATH:      dstPath + strings.TrimPrefix(record.Path(), srcPath),
+		})
+
+		if record.ID() == source.ID() {
+			copied.SetParentID(parent.ID()).SetName(path.Base(dstPath))
+
+			if source.IsFile() {
+				copied.SetExtension(pathExtension(dstPath))
+			}
+		}
+
+		err = store.recordCopy(record.ID(), copied)
+
+		if err != nil {
+			return err
+		}
+
+		if !record.IsFile() {
+			continue
+		}
+
+		err = store.chunksCopy(record.ID(), newID)
+
+		if err != nil {
+			return err
+		}
+
+		// A copy starts its own history, with the copied contents
+		if store.settings().versioningEnabled && record.Size() != "0" {
+			err = store.versionCreate(newID, VersionOptions{})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordCopy inserts a copy of the record with the ID, taking the ID,
+// parent ID, name, extension and path from the copied record. The contents
+// are copied by the database, without being loaded in memory, unless they
+// are kept in the content table, the copy sharing their content row.
+func (store *Store) recordCopy(id string, copied *Record) error {
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+	// The copy shares the content row of the record
+	contentID, err := store.contentsCopy(id)
+
+	if err != nil {
+		return err
+	}
+
+	// The sub query takes the dialect of the insert
+	selectQuery := goqu.From(store.tableName).
+		Prepared(true).
+		Select(
+			goqu.V(copied.ID()),
+			goqu.V(copied.ParentID()),
+			goqu.C(COLUMN_TYPE),
+			goqu.V(copied.Name()),
+			goqu.C(COLUMN_CONTENTS),
+			goqu.C(COLUMN_CODEC),
+			goqu.V(contentID),
+			goqu.C(COLUMN_HASH),
+			goqu.C(COLUMN_SIZE),
+			goqu.V(copied.Extension()),
+			goqu.V(copied.Path()),
+			goqu.V(now),
+			goqu.V(now),
+			goqu.V(sb.NULL_DATETIME),
+		).
+		Where(goqu.C(COLUMN_ID).Eq(id))
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Insert(store.tableName).
+		Prepared(true).
+		Cols(
+			COLUMN_ID,
+			COLUMN_PARENT_ID,
+			COLUMN_TYPE,
+			COLUMN_NAME,
+			COLUMN_CONTENTS,
+			COLUMN_CODEC,
+			COLUMN_CONTENT_ID,
+			COLUMN_HASH,
+			COLUMN_SIZE,
+			COLUMN_EXTENSION,
+			COLUMN_PATH,
+			COLUMN_CREATED_AT,
+			COLUMN_UPDATED_AT,
+			COLUMN_DELETED_AT,
+		).
+		FromQuery(selectQuery).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return store.uniqueError(err, copied.Path())
+}
+
+// chunksCopy copies the chunks of a record to another record,
+// the data being copied by the database, without being loaded in memory
+func (store *Store) chunksCopy(fromRecordID string, toRecordID string) error {
+	chunks, err := store.chunkList(fromRecordID)
+
+	if err != nil {
+		return err
+	}
+
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+	for _, chunk := range chunks {
+		selectQuery := goqu.From(store.chunkTableName).
+			Prepared(true).
+			Select(
+				goqu.V(uid.HumanUid()),
+				goqu.V(toRecordID),
+				goqu.C(COLUMN_SEQUENCE),
+				goqu.C(COLUMN_SIZE),
+				goqu.C(COLUMN_DATA),
+				goqu.V(now),
+			).
+			Where(goqu.C(COLUMN_RECORD_ID).Eq(fromRecordID), goqu.C(COLUMN_SEQUENCE).Eq(chunk.sequence))
+
+		sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+			Insert(store.chunkTableName).
+			Prepared(true).
+			Cols(COLUMN_ID, COLUMN_RECORD_ID, COLUMN_SEQUENCE, COLUMN_SIZE, COLUMN_DATA, COLUMN_CREATED_AT).
+			FromQuery(selectQuery).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if store.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = store.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func initCopyStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+		ChunkSize:          4,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/readme.txt", []byte("README"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	writer, err := store.CreateWriter("/docs/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("STREAMED CONTENTS"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func TestStoreCopyFile(t *testing.T) {
+	store := initCopyStore(t, "file_copy_file")
+
+	err := store.Copy("/docs/readme.txt", "/readme.md", CopyOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	original, err := store.RecordFindByPath("/docs/readme.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	copied, err := store.RecordFindByPath("/readme.md", RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if copied == nil {
+		t.Fatal("Copy MUST NOT be nil")
+	}
+
+	if copied.ID() == original.ID() {
+		t.Fatal("Copy MUST have a new ID")
+	}
+
+	if copied.ParentID() != ROOT_ID || copied.Name() != "readme.md" || copied.Extension() != "md" {
+		t.Fatal("unexpected copy:", copied.Data())
+	}
+
+	if copied.Contents() != "README" || copied.Size() != original.Size() {
+		t.Fatal("unexpected copy:", copied.Data())
+	}
+
+	err = store.Copy("/docs/readme.txt", "/readme.md", CopyOptions{})
+
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, found:", err)
+	}
+
+	err = store.WriteFile("/docs/readme.txt", []byte("NEW README"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Copy("/docs/readme.txt", "/readme.md", CopyOptions{Overwrite: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/readme.md")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "NEW README" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreCopyDirectory(t *testing.T) {
+	store := initCopyStore(t, "file_copy_directory")
+
+	err := store.Copy("/docs", "/backup", CopyOptions{})
+
+	if err == nil {
+		t.Fatal("must return error as a directory requires a recursive copy")
+	}
+
+	err = store.Copy("/docs", "/docs/2026/docs", CopyOptions{Recursive: true})
+
+	if err == nil {
+		t.Fatal("must return error as a directory cannot be copied into itself")
+	}
+
+	err = store.Copy("/docs", "/backup", CopyOptions{Recursive: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	backup, err := store.RecordFindByPath("/backup", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	year, err := store.RecordFindByPath("/backup/2026", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if backup == nil || year == nil {
+		t.Fatal("Directories MUST be copied")
+	}
+
+	if year.ParentID() != backup.ID() || backup.ParentID() != ROOT_ID {
+		t.Fatal("unexpected parent IDs:", backup.Data(), year.Data())
+	}
+
+	streamed, err := store.RecordFindByPath("/backup/2026/streamed.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if streamed == nil || streamed.ParentID() != year.ID() {
+		t.Fatal("unexpected record:", streamed)
+	}
+
+	contents, err := store.ReadFile("/backup/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "STREAMED CONTENTS" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	// The copy is independent of the original
+	err = store.RemoveAll("/docs")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err = store.ReadFile("/backup/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "STREAMED CONTENTS" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	contents, err = store.ReadFile("/backup/readme.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "README" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreCopyRollsBackOnFailure(t *testing.T) {
+	store := initCopyStore(t, "file_copy_rollback")
+
+	err := store.Copy("/docs", "/missing/backup", CopyOptions{Recursive: true})
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/missing"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST be copied, found:", count)
+	}
+}
@@ -0,0 +1,303 @@
+package sqlfilestore
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gouniverse/sb"
+	"github.com/samber/lo"
+)
+
+// ListPage returns a page of the records matching the options, starting
+// after the position of the cursor (the first page for an empty cursor),
+// and the cursor of the next page, empty for the last page.
+//
+// Unlike Offset, the cursor is a position in the order of the records (the
+// value of the sort column, plus the ID to break the ties), so that a page
+// is found with an index seek however deep it is, and the records inserted
+// or deleted between two pages do not shift the pages.
+//
+// The options can have a single OrderBy clause, the records being sorted
+// by path in ascending order by default. Limit is the size of the page,
+// DEFAULT_PAGE_SIZE by default, and Offset is not supported.
+//
+// The cursors are opaque tokens, signed with the cursor secret, so that
+// they can be handed to the clients of an API. A cursor which was altered,
+// or used with a different order, fails with ErrInvalidCursor.
+func (store *Store) ListPage(options RecordQueryOptions, cursor string) ([]Record, string, error) {
+	return operations{store}.listPage(options, cursor)
+}
+
+// ListPage returns a page of the records matching the options, starting
+// after the position of the cursor, and the cursor of the next page.
+// See Store.ListPage.
+func (store *MemoryStore) ListPage(options RecordQueryOptions, cursor string) ([]Record, string, error) {
+	return operations{store}.listPage(options, cursor)
+}
+
+// SetCursorSecret sets the secret the ListPage cursors are signed with.
+// The cursors signed with the previous secret are no longer valid.
+func (store *Store) SetCursorSecret(secret []byte) {
+	store.cursorSecret = secret
+}
+
+// SetCursorSecret sets the secret the ListPage cursors are signed with
+func (store *MemoryStore) SetCursorSecret(secret []byte) {
+	store.cursorSecret = secret
+}
+
+// == PRIVATE METHODS ========================================================
+
+// listPage implements ListPage
+func (store operations) listPage(options RecordQueryOptions, cursor string) ([]Record, string, error) {
+	options, clause, limit, err := listPageOptions(options)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	var position *cursorPosition
+
+	if cursor != "" {
+		decoded, err := cursorDecode(store.settings().cursorSecret, cursor, clause)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		position = &decoded
+	}
+
+	// One more record tells whether there is a next page
+	records, err := store.recordListAfter(options, clause, position, limit+1)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return listPageResult(store.settings().cursorSecret, records, clause, limit)
+}
+
+// recordListAfter returns at most limit records matching the options,
+// sorted by the clause then by ID, after the position if not nil
+func (store *Store) recordListAfter(options RecordQueryOptions, clause OrderClause, position *cursorPosition, limit int) ([]Record, error) {
+	q := store.recordQuery(options).Prepared(true)
+
+	if position != nil {
+		q = q.Where(keysetCondition(clause, *position))
+	}
+
+	if clause.IsAscending() {
+		q = q.Order(goqu.C(clause.Column).Asc(), goqu.C(COLUMN_ID).Asc())
+	} else {
+		q = q.Order(goqu.C(clause.Column).Desc(), goqu.C(COLUMN_ID).Desc())
+	}
+
+	columns := options.Columns
+
+	if len(columns) == 0 && !options.WithContents {
+		columns = fsMetadataColumns
+	}
+
+	if len(columns) > 0 {
+		q = q.Select(lo.Map(contentsColumns(columns), func(column string, _ int) any {
+			return goqu.C(column)
+		})...)
+	}
+
+	sqlStr, params, errSql := q.Limit(uint(limit)).ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := lo.Map(rows, func(row map[string]string, _ int) Record {
+		return *NewRecordFromExistingData(row)
+	})
+
+	if lo.Contains(columns, COLUMN_CONTENTS) || len(columns) == 0 {
+		err = store.contentsLoad(records)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// cursorPosition is the position a cursor points after
+type cursorPosition struct {
+	Column    string `json:"c"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        string `json:"i"`
+}
+
+// listPageOptions checks the options of ListPage, returning the options
+// of the query, the order of the page and its size
+func listPageOptions(options RecordQueryOptions) (RecordQueryOptions, OrderClause, int, error) {
+	if options.Offset > 0 {
+		return options, OrderClause{}, 0, errors.New("offset is not supported with a cursor")
+	}
+
+	if len(options.OrderBy) > 1 {
+		return options, OrderClause{}, 0, errors.New("a cursor supports a single order by clause")
+	}
+
+	clause := OrderClause{Column: COLUMN_PATH, SortOrder: sb.ASC}
+
+	if len(options.OrderBy) == 1 {
+		clause = options.OrderBy[0]
+	}
+
+	limit := options.Limit
+
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_SIZE
+	}
+
+	// The cursor of the next page needs the sort column and the ID
+	if len(options.Columns) > 0 {
+		options.Columns = lo.Uniq(append(options.Columns, COLUMN_ID, clause.Column))
+	}
+
+	options.OrderBy = nil
+	options.Limit = 0
+
+	return options, clause, limit, nil
+}
+
+// listPageResult returns the page of the records, fetched with one more
+// record than the page size, and the cursor of the next page
+func listPageResult(secret []byte, records []Record, clause OrderClause, limit int) ([]Record, string, error) {
+	if len(records) <= limit {
+		return records, "", nil
+	}
+
+	records = records[:limit]
+
+	next, err := cursorEncode(secret, clause, &records[limit-1])
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return records, next, nil
+}
+
+// keysetCondition returns the condition selecting the records after the
+// position, in the order of the clause
+func keysetCondition(clause OrderClause, position cursorPosition) exp.Expression {
+	column := goqu.C(clause.Column)
+	id := goqu.C(COLUMN_ID)
+
+	if clause.IsAscending() {
+		return goqu.Or(
+			column.Gt(position.Value),
+			goqu.And(column.Eq(position.Value), id.Gt(position.ID)),
+		)
+	}
+
+	return goqu.Or(
+		column.Lt(position.Value),
+		goqu.And(column.Eq(position.Value), id.Lt(position.ID)),
+	)
+}
+
+// cursorEncode returns the signed cursor pointing after the record
+func cursorEncode(secret []byte, clause OrderClause, record *Record) (string, error) {
+	payload, err := json.Marshal(cursorPosition{
+		Column:    clause.Column,
+		SortOrder: cursorSortOrder(clause),
+		Value:     record.Get(clause.Column),
+		ID:        record.ID(),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(cursorSignature(secret, payload)), nil
+}
+
+// cursorDecode returns the position of the cursor, checking its signature
+// and that it was issued for the same order
+func cursorDecode(secret []byte, cursor string, clause OrderClause) (cursorPosition, error) {
+	position := cursorPosition{}
+	encoding := base64.RawURLEncoding
+
+	encodedPayload, encodedSignature, found := strings.Cut(cursor, ".")
+
+	if !found {
+		return position, wrapError(ErrInvalidCursor, "cursor is malformed")
+	}
+
+	payload, errPayload := encoding.DecodeString(encodedPayload)
+	signature, errSignature := encoding.DecodeString(encodedSignature)
+
+	if errPayload != nil || errSignature != nil {
+		return position, wrapError(ErrInvalidCursor, "cursor is malformed")
+	}
+
+	if !hmac.Equal(signature, cursorSignature(secret, payload)) {
+		return position, wrapError(ErrInvalidCursor, "cursor signature does not match")
+	}
+
+	if err := json.Unmarshal(payload, &position); err != nil {
+		return position, wrapError(ErrInvalidCursor, "cursor is malformed")
+	}
+
+	if position.Column != clause.Column || position.SortOrder != cursorSortOrder(clause) {
+		return position, wrapError(ErrInvalidCursor, "cursor was issued for a different order")
+	}
+
+	return position, nil
+}
+
+// cursorSignature returns the HMAC-SHA256 of the cursor payload
+func cursorSignature(secret []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// cursorSortOrder returns the normalized sort order of the clause
+func cursorSortOrder(clause OrderClause) string {
+	if clause.IsAscending() {
+		return sb.ASC
+	}
+
+	return sb.DESC
+}
+
+// cursorSecretGenerate returns a random secret, the cursors being valid
+// for the lifetime of the store only
+func cursorSecretGenerate() []byte {
+	secret := make([]byte, 32)
+
+	// crypto/rand.Read does not fail on the supported platforms
+	_, _ = rand.Read(secret)
+
+	return secret
+}
@@ -0,0 +1,162 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gouniverse/sb"
+)
+
+func TestStoreListPage(t *testing.T) {
+	testListPage(t, initTxStore(t, "file_list_page"))
+}
+
+func TestMemoryStoreListPage(t *testing.T) {
+	testListPage(t, NewMemoryStore())
+}
+
+func testListPage(t *testing.T, store StoreInterface) {
+	err := store.Mkdir("/big")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for i := 0; i < 25; i++ {
+		// The sizes have ties, broken by the ID
+		err := store.WriteFile(fmt.Sprintf("/big/file%02d.txt", i), []byte(strings.Repeat("x", i%5)))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	big, err := store.RecordFindByPath("/big", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	listAll := func(options RecordQueryOptions, insert func(page int)) []Record {
+		all := []Record{}
+		cursor := ""
+
+		for page := 0; ; page++ {
+			records, next, err := store.ListPage(options, cursor)
+
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if next != "" && len(records) != options.Limit {
+				t.Fatal("A page before the last MUST be full, found:", len(records))
+			}
+
+			all = append(all, records...)
+
+			if next == "" {
+				return all
+			}
+
+			if insert != nil {
+				insert(page)
+			}
+
+			cursor = next
+		}
+	}
+
+	// Records inserted before the cursor do not shift the next pages
+	all := listAll(RecordQueryOptions{ParentID: big.ID(), Limit: 10}, func(page int) {
+		err := store.WriteFile(fmt.Sprintf("/big/file00-%d.txt", page), []byte("new"))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+
+	if len(all) != 25 {
+		t.Fatal("expected 25 records, found:", len(all))
+	}
+
+	for i, record := range all {
+		if record.Path() != fmt.Sprintf("/big/file%02d.txt", i) {
+			t.Fatal("expected the records in path order, found:", i, record.Path())
+		}
+	}
+
+	bySize := listAll(RecordQueryOptions{
+		ParentID: big.ID(),
+		Type:     TYPE_FILE,
+		OrderBy:  []OrderClause{{Column: COLUMN_SIZE, SortOrder: sb.DESC}},
+		Columns:  []string{COLUMN_PATH},
+		Limit:    4,
+	}, nil)
+
+	if len(bySize) != 27 {
+		t.Fatal("expected 27 records, found:", len(bySize))
+	}
+
+	seen := map[string]bool{}
+
+	for i, record := range bySize {
+		if seen[record.ID()] {
+			t.Fatal("The record MUST be listed once:", record.Path())
+		}
+
+		seen[record.ID()] = true
+
+		if record.Contents() != "" {
+			t.Fatal("Only the selected columns MUST be returned:", record.Data())
+		}
+
+		if i > 0 && memoryValueLess(COLUMN_SIZE, bySize[i-1].Data(), record.Data()) {
+			t.Fatal("expected the records by descending size, found:", bySize[i-1].Size(), record.Size())
+		}
+	}
+
+	_, next, err := store.ListPage(RecordQueryOptions{ParentID: big.ID(), Limit: 5}, "")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	payload, signature, _ := strings.Cut(next, ".")
+
+	for _, cursor := range []string{
+		"garbage",
+		payload + "." + signature[1:],
+		strings.ToUpper(payload) + "." + signature,
+	} {
+		_, _, err = store.ListPage(RecordQueryOptions{ParentID: big.ID(), Limit: 5}, cursor)
+
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Fatal("expected ErrInvalidCursor, found:", err)
+		}
+	}
+
+	_, _, err = store.ListPage(RecordQueryOptions{
+		ParentID: big.ID(),
+		OrderBy:  []OrderClause{{Column: COLUMN_PATH, SortOrder: sb.DESC}},
+	}, next)
+
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatal("A cursor used with a different order MUST fail, found:", err)
+	}
+
+	store.SetCursorSecret([]byte("rotated"))
+
+	_, _, err = store.ListPage(RecordQueryOptions{ParentID: big.ID(), Limit: 5}, next)
+
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatal("A cursor signed with another secret MUST fail, found:", err)
+	}
+
+	_, _, err = store.ListPage(RecordQueryOptions{Offset: 5}, "")
+
+	if err == nil {
+		t.Fatal("Offset MUST NOT be supported with a cursor")
+	}
+}
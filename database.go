@@ -0,0 +1,196 @@
+package sqlfilestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// queryable is the subset of methods shared by *sql.DB and *sql.Tx
+type queryable interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// database returns the transaction the store is bound to,
+// or the database if there is none
+func (store *Store) database() queryable {
+	if store.tx != nil {
+		return store.tx
+	}
+
+	return store.db
+}
+
+// executeSql executes the SQL statement on the database (or transaction),
+// with the context the store is bound to
+func (store *Store) executeSql(sqlStr string, params ...any) (sql.Result, error) {
+	return store.database().ExecContext(store.contextOrBackground(), sqlStr, params...)
+}
+
+// selectToMapString runs the query on the database (or transaction)
+// and returns the rows as maps of column name to string value
+func (store *Store) selectToMapString(sqlStr string, params ...any) ([]map[string]string, error) {
+	rows, err := store.database().QueryContext(store.contextOrBackground(), sqlStr, params...)
+
+	if err != nil {
+		return []map[string]string{}, err
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+
+	if err != nil {
+		return []map[string]string{}, err
+	}
+
+	list := []map[string]string{}
+
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err = rows.Scan(pointers...)
+
+		if err != nil {
+			return []map[string]string{}, err
+		}
+
+		row := make(map[string]string, len(columns))
+
+		for i, column := range columns {
+			row[column] = valueToString(values[i])
+		}
+
+		list = append(list, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return []map[string]string{}, err
+	}
+
+	return list, nil
+}
+
+// WithTx executes the function in a database transaction. The function
+// receives a copy of the store bound to the transaction, and every
+// operation made through it (record creates, updates, deletes, queries,
+// file operations) runs on that transaction. The transaction is committed
+// if the function returns nil, and rolled back if it returns an error
+// or panics.
+//
+// If the store is already bound to a transaction, the function joins it,
+// and the outer transaction decides whether to commit.
+func (store *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	return store.withContext(ctx).transaction(fn)
+}
+
+// Transaction executes the function in a database transaction, like
+// WithTx, for callers which only know the store by its interface
+func (store *Store) Transaction(ctx context.Context, fn func(tx StoreInterface) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	return store.WithTx(ctx, func(tx *Store) error {
+		return fn(tx)
+	})
+}
+
+// UsingTx returns a copy of the store bound to a transaction started by
+// the caller, so that store operations can be combined with changes to
+// other tables. The caller remains responsible for committing or rolling
+// back the transaction.
+func (store *Store) UsingTx(tx *sql.Tx) *Store {
+	txStore := *store
+	txStore.tx = tx
+	return &txStore
+}
+
+// Tx returns the transaction the store is bound to,
+// or nil if the store is not bound to a transaction
+func (store *Store) Tx() *sql.Tx {
+	return store.tx
+}
+
+// transaction executes the function in a database transaction started
+// with the context the store is bound to, see WithTx
+func (store *Store) transaction(fn func(txStore *Store) error) (err error) {
+	if store.tx != nil {
+		return fn(store)
+	}
+
+	tx, err := store.db.BeginTx(store.contextOrBackground(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	txStore := store.UsingTx(tx)
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+
+		if err != nil {
+			if errRollback := tx.Rollback(); errRollback != nil && store.debugEnabled {
+				log.Println("rollback error: " + errRollback.Error())
+			}
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	return fn(txStore)
+}
+
+// transactionRun executes the function in a database transaction,
+// see transaction
+func (store *Store) transactionRun(fn func(txStorage recordStorage) error) error {
+	return store.transaction(func(txStore *Store) error {
+		return fn(txStore)
+	})
+}
+
+// inTransaction returns whether the store is bound to a transaction
+func (store *Store) inTransaction() bool {
+	return store.tx != nil
+}
+
+// valueToString converts a scanned database value to string in the same
+// way the sb package does, except for times which are formatted the way
+// they are written, so that they can be compared and written back as is
+func valueToString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	case time.Time:
+		return v.Format(time.DateTime)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,157 @@
+package sqlfilestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func initTxStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	// A single connection, as each connection opens its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func TestStoreWithTxCommits(t *testing.T) {
+	store := initTxStore(t, "file_with_tx_commit")
+
+	err := store.WithTx(context.Background(), func(tx *Store) error {
+		if tx.Tx() == nil {
+			t.Fatal("Store MUST be bound to the transaction")
+		}
+
+		record, err := tx.Create("/notes.txt")
+
+		if err != nil {
+			return err
+		}
+
+		record.SetContents("NOTES").SetSize("5")
+
+		return tx.RecordUpdate(record)
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if store.Tx() != nil {
+		t.Fatal("Store MUST NOT be bound to the transaction")
+	}
+
+	contents, err := store.ReadFile("/notes.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "NOTES" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreWithTxRollsBack(t *testing.T) {
+	store := initTxStore(t, "file_with_tx_rollback")
+
+	errFailed := errors.New("failed")
+
+	err := store.WithTx(context.Background(), func(tx *Store) error {
+		err := tx.MkdirAll("/docs/2026")
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.WriteFile("/docs/2026/report.txt", []byte("REPORT"))
+
+		if err != nil {
+			return err
+		}
+
+		// Nested transactions join the outer one
+		err = tx.WithTx(context.Background(), func(nested *Store) error {
+			if nested.Tx() != tx.Tx() {
+				t.Fatal("Nested transaction MUST join the outer one")
+			}
+
+			return nested.Rename("/docs/2026", "/docs/2027")
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return errFailed
+	})
+
+	if !errors.Is(err, errFailed) {
+		t.Fatal("expected the function error, found:", err)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST be created, found:", count)
+	}
+}
+
+func TestStoreUsingTx(t *testing.T) {
+	store := initTxStore(t, "file_using_tx")
+
+	tx, err := store.db.Begin()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = tx.Exec("CREATE TABLE audit (path TEXT)")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.UsingTx(tx).WriteFile("/notes.txt", []byte("NOTES"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO audit (path) VALUES (?)", "/notes.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = tx.Rollback()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("/notes.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record != nil {
+		t.Fatal("Record MUST be rolled back with the caller's transaction")
+	}
+}
@@ -0,0 +1,295 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestStoreHash(t *testing.T) {
+	testHash(t, initTxStore(t, "file_hash"))
+}
+
+func TestMemoryStoreHash(t *testing.T) {
+	testHash(t, NewMemoryStore())
+}
+
+func testHash(t *testing.T, store StoreInterface) {
+	hashOf := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	findHash := func(filePath string) string {
+		record, err := store.RecordFindByPath(filePath, RecordQueryOptions{})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if record == nil {
+			t.Fatal("record not found:", filePath)
+		}
+
+		return record.Hash()
+	}
+
+	err := store.MkdirAll("/docs")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/logo.png", []byte("LOGO"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if findHash("/docs/logo.png") != hashOf([]byte("LOGO")) {
+		t.Fatal("The hash MUST be the SHA-256 of the contents, found:", findHash("/docs/logo.png"))
+	}
+
+	if findHash("/docs") != "" {
+		t.Fatal("A directory MUST have no hash, found:", findHash("/docs"))
+	}
+
+	err = store.WriteFile("/docs/logo.png", []byte("NEW LOGO"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if findHash("/docs/logo.png") != hashOf([]byte("NEW LOGO")) {
+		t.Fatal("The hash MUST follow the contents, found:", findHash("/docs/logo.png"))
+	}
+
+	// Streamed over several chunks
+	streamed := bytes.Repeat([]byte("STREAMED"), DEFAULT_CHUNK_SIZE/4)
+
+	writer, err := store.CreateWriter("/docs/streamed.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write(streamed)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if findHash("/docs/streamed.bin") != hashOf(streamed) {
+		t.Fatal("The hash MUST be the SHA-256 of the streamed contents, found:", findHash("/docs/streamed.bin"))
+	}
+
+	err = store.Copy("/docs", "/copy", CopyOptions{Recursive: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if findHash("/copy/logo.png") != hashOf([]byte("NEW LOGO")) || findHash("/copy/streamed.bin") != hashOf(streamed) {
+		t.Fatal("A copy MUST keep the hash")
+	}
+}
+
+func TestStoreContentDeduplication(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_dedup",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+	})
+
+	contentTableName := store.contentTableName
+	logo := []byte{0x89, 0x50, 0x4E, 0x47, 0x00, 0xFF}
+
+	for _, filePath := range []string{"/a.png", "/b.png", "/c.png"} {
+		err := store.WriteFile(filePath, logo)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if tableCount(t, store, contentTableName, "1 = 1") != 1 {
+		t.Fatal("Identical contents MUST be kept once")
+	}
+
+	if tableCount(t, store, contentTableName, "ref_count = 3") != 1 {
+		t.Fatal("The content row MUST count its references")
+	}
+
+	records, err := store.RecordList(RecordQueryOptions{Type: TYPE_FILE, WithContents: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, record := range records {
+		if record.ContentID() != records[0].ContentID() || !bytes.Equal(record.ContentsBytes(), logo) {
+			t.Fatal("The records MUST share the content row:", record.Data())
+		}
+	}
+
+	// Changing the contents releases the shared row
+	err = store.WriteFile("/c.png", []byte("CHANGED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "ref_count = 2") != 1 || tableCount(t, store, contentTableName, "ref_count = 1") != 1 {
+		t.Fatal("The changed record MUST release the shared row")
+	}
+
+	// A soft deleted record keeps its reference, to be restorable
+	a, err := store.RecordFindByPath("/a.png", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(a)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "ref_count = 2") != 1 {
+		t.Fatal("A soft deleted record MUST keep its reference")
+	}
+
+	err = store.RecordDelete(a)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Remove("/b.png")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, contentTableName, "1 = 1") != 1 {
+		t.Fatal("The unreferenced contents MUST be deleted")
+	}
+
+	contents, err := store.ReadFile("/c.png")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "CHANGED" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreContentDeduplicationRetriedCreate(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_dedup_retried",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+	})
+
+	err := store.WriteFile("/a.txt", []byte("aaa"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	file := NewFile().
+		SetParentID(ROOT_ID).
+		SetName("a.txt").
+		SetPath("/a.txt").
+		SetExtension("txt").
+		SetSize("3").
+		SetContents("aaa")
+
+	err = store.RecordCreate(file)
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	// Retried with other contents at a free path, the record keeping the
+	// hash of the failed attempt
+	file.SetName("c.txt").SetPath("/c.txt").SetContents("ccc")
+
+	err = store.RecordCreate(file)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/c.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "ccc" {
+		t.Fatal("The contents MUST NOT be deduplicated by a stale hash, found:", string(contents))
+	}
+
+	if file.Hash() != contentsHash("ccc") {
+		t.Fatal("The hash MUST be that of the contents written, found:", file.Hash())
+	}
+}
+
+func TestStoreContentsMigrateToContentTableDeduplication(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_dedup_migrate",
+		BinaryContentsEnabled: true,
+	})
+
+	for _, filePath := range []string{"/a.pdf", "/b.pdf", "/c.pdf"} {
+		err := store.WriteFile(filePath, []byte("SAME PDF"))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	err := store.WriteFile("/d.pdf", []byte("OTHER PDF"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store.contentTableEnabled = true
+
+	err = store.ContentsMigrateToContentTable()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if tableCount(t, store, store.contentTableName, "1 = 1") != 2 {
+		t.Fatal("The migrated identical contents MUST be kept once")
+	}
+
+	if tableCount(t, store, store.contentTableName, "ref_count = 3") != 1 {
+		t.Fatal("The migrated content row MUST count its references")
+	}
+
+	contents, err := store.ReadFile("/b.pdf")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "SAME PDF" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
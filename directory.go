@@ -0,0 +1,111 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+// DirectoryEnsure returns the directory at the path, creating it along
+// with any missing ancestors, like MkdirAll. The directories are created
+// in a single transaction, each with the ID of its parent as parent ID.
+//
+// It is safe for concurrent use: when a concurrent request creates one of
+// the directories first, the transaction is retried and the directory
+// created by the other request is returned. The errors returned are of
+// type *fs.PathError.
+func (store *Store) DirectoryEnsure(dirPath string) (*Record, error) {
+	return operations{store}.directoryEnsure(dirPath)
+}
+
+// DirectoryEnsure returns the directory at the path, creating it along
+// with any missing ancestors, like MkdirAll. A directory created
+// concurrently by another goroutine is found and used.
+func (store *MemoryStore) DirectoryEnsure(dirPath string) (*Record, error) {
+	return operations{store}.directoryEnsure(dirPath)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// directoryEnsure implements DirectoryEnsure
+func (store operations) directoryEnsure(dirPath string) (*Record, error) {
+	dirPath, errPath := store.cleanPath("mkdir", dirPath)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	var directory *Record
+	var err error
+
+	for attempt := 0; attempt < directoryEnsureAttempts; attempt++ {
+		err = store.transaction(func(txStore operations) error {
+			directory, err = txStore.directoriesCreate(dirPath)
+			return err
+		})
+
+		// A transaction joined can not be retried, it is up to its owner
+		if !errors.Is(err, ErrExists) || store.inTransaction() {
+			break
+		}
+	}
+
+	return directory, err
+}
+
+// directoryEnsureAttempts is the number of times DirectoryEnsure tries to
+// create the directories, when they are created concurrently
+const directoryEnsureAttempts = 3
+
+// directoriesCreate creates the missing directories of the (clean) path,
+// returning the last one, and must be called in a transaction
+func (store operations) directoriesCreate(dirPath string) (*Record, error) {
+	parent, err := store.recordFindByPath(ROOT_PATH, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "mkdir", Path: dirPath, Err: err}
+	}
+
+	if parent == nil {
+		return nil, &fs.PathError{Op: "mkdir", Path: dirPath, Err: errors.New("root directory not found")}
+	}
+
+	currentPath := ""
+
+	for _, name := range strings.Split(strings.TrimPrefix(dirPath, ROOT_PATH), PATH_SEPARATOR) {
+		if name == "" {
+			continue
+		}
+
+		currentPath += PATH_SEPARATOR + name
+
+		record, err := store.recordFindByPath(currentPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+		if err != nil {
+			return nil, &fs.PathError{Op: "mkdir", Path: currentPath, Err: err}
+		}
+
+		if record == nil {
+			record = NewDirectory().
+				SetParentID(parent.ID()).
+				SetName(name).
+				SetPath(currentPath)
+
+			// No suffix is added, an existing directory is an error,
+			// for DirectoryEnsure to retry and find it
+			err = store.recordCreate(record, false)
+
+			if err != nil {
+				return nil, &fs.PathError{Op: "mkdir", Path: currentPath, Err: err}
+			}
+		}
+
+		if !record.IsDirectory() {
+			return nil, &fs.PathError{Op: "mkdir", Path: currentPath, Err: ErrNotDirectory}
+		}
+
+		parent = record
+	}
+
+	return parent, nil
+}
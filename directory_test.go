@@ -0,0 +1,122 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestStoreDirectoryEnsure(t *testing.T) {
+	testDirectoryEnsure(t, initTxStore(t, "file_directory_ensure"))
+}
+
+func TestMemoryStoreDirectoryEnsure(t *testing.T) {
+	testDirectoryEnsure(t, NewMemoryStore())
+}
+
+func testDirectoryEnsure(t *testing.T, store StoreInterface) {
+	directory, err := store.DirectoryEnsure("/uploads/2026/10/18")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if directory == nil || !directory.IsDirectory() || directory.Path() != "/uploads/2026/10/18" {
+		t.Fatal("The leaf directory MUST be returned:", directory)
+	}
+
+	// The parent IDs lead to the root
+	expectedPaths := []string{"/uploads/2026/10", "/uploads/2026", "/uploads", "/"}
+
+	current := directory
+
+	for _, expectedPath := range expectedPaths {
+		parent, err := store.RecordFindByID(current.ParentID(), RecordQueryOptions{})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if parent == nil || parent.Path() != expectedPath {
+			t.Fatal("expected parent:", expectedPath, "found:", parent)
+		}
+
+		current = parent
+	}
+
+	again, err := store.DirectoryEnsure("uploads/2026/10/18/")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if again.ID() != directory.ID() {
+		t.Fatal("The existing directory MUST be returned:", again.ID(), directory.ID())
+	}
+
+	root, err := store.DirectoryEnsure("/")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if root.ID() != ROOT_ID {
+		t.Fatal("The root directory MUST be returned:", root.ID())
+	}
+
+	err = store.WriteFile("/uploads/readme.txt", []byte("README"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.DirectoryEnsure("/uploads/readme.txt/2026")
+
+	if !errors.Is(err, ErrNotDirectory) {
+		t.Fatal("expected ErrNotDirectory, found:", err)
+	}
+
+	// Concurrent requests get the same directories
+	var wg sync.WaitGroup
+
+	ids := make([]string, 10)
+	errs := make([]error, 10)
+
+	for i := range ids {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			directory, err := store.DirectoryEnsure("/uploads/2026/11/01")
+
+			errs[i] = err
+
+			if directory != nil {
+				ids[i] = directory.ID()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := range ids {
+		if errs[i] != nil {
+			t.Fatal("unexpected error:", errs[i])
+		}
+
+		if ids[i] != ids[0] {
+			t.Fatal("The same directory MUST be returned:", ids[i], ids[0])
+		}
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{Path: "/uploads/2026/11"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("The directory MUST be created once, found:", count)
+	}
+}
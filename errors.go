@@ -0,0 +1,83 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+)
+
+// The errors returned by the store can be checked with errors.Is against
+// the sentinel errors below. ErrNotFound, ErrExists and ErrInvalidPath
+// also match fs.ErrNotExist, fs.ErrExist and fs.ErrInvalid respectively,
+// so the errors can be handled the same way as those of the os package.
+var (
+	// ErrNotFound is returned when a record does not exist
+	ErrNotFound error = &storeError{message: "record not found", err: fs.ErrNotExist}
+
+	// ErrExists is returned when a record already exists at the path
+	ErrExists error = &storeError{message: "record already exists", err: fs.ErrExist}
+
+	// ErrInvalidPath is returned when a path or a name is not valid
+	ErrInvalidPath error = &storeError{message: "invalid path", err: fs.ErrInvalid}
+
+	// ErrEmptyID is returned when a record ID is required but empty
+	ErrEmptyID error = &storeError{message: "record id is empty", err: fs.ErrInvalid}
+
+	// ErrNotEmpty is returned when deleting a directory which is not empty
+	ErrNotEmpty = errors.New("directory is not empty")
+
+	// ErrNotDirectory is returned when a directory is expected
+	ErrNotDirectory = errors.New("not a directory")
+
+	// ErrInvalidCursor is returned when a ListPage cursor was altered,
+	// or is used with a different order than it was issued for
+	ErrInvalidCursor error = &storeError{message: "invalid cursor", err: fs.ErrInvalid}
+)
+
+// The errors returned when a path or a name breaks the PathRules.
+// All of them match ErrInvalidPath (and fs.ErrInvalid).
+var (
+	ErrEmptyName          = wrapError(ErrInvalidPath, "name is empty")
+	ErrNameTooLong        = wrapError(ErrInvalidPath, "name is too long")
+	ErrExtensionTooLong   = wrapError(ErrInvalidPath, "extension is too long")
+	ErrForbiddenCharacter = wrapError(ErrInvalidPath, "name contains a forbidden character")
+	ErrReservedName       = wrapError(ErrInvalidPath, "name is reserved")
+	ErrPathTooLong        = wrapError(ErrInvalidPath, "path is too long")
+	ErrPathTooDeep        = wrapError(ErrInvalidPath, "path is too deep")
+)
+
+// NameError records the name which breaks the PathRules,
+// and the error of the rule it breaks (e.g. ErrNameTooLong)
+type NameError struct {
+	Name string
+	Err  error
+}
+
+func (e *NameError) Error() string {
+	return e.Err.Error() + ": " + strconv.Quote(e.Name)
+}
+
+func (e *NameError) Unwrap() error {
+	return e.Err
+}
+
+// storeError is an error with its own message, wrapping
+// the sentinel (or fs) error it matches with errors.Is
+type storeError struct {
+	message string
+	err     error
+}
+
+func (e *storeError) Error() string {
+	return e.message
+}
+
+func (e *storeError) Unwrap() error {
+	return e.err
+}
+
+// wrapError returns an error with the message, matching
+// the sentinel error with errors.Is
+func wrapError(err error, message string) error {
+	return &storeError{message: message, err: err}
+}
@@ -0,0 +1,211 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestErrorsMatchFsErrors(t *testing.T) {
+	if !errors.Is(ErrNotFound, fs.ErrNotExist) {
+		t.Fatal("ErrNotFound MUST match fs.ErrNotExist")
+	}
+
+	if !errors.Is(ErrExists, fs.ErrExist) {
+		t.Fatal("ErrExists MUST match fs.ErrExist")
+	}
+
+	if !errors.Is(ErrInvalidPath, fs.ErrInvalid) {
+		t.Fatal("ErrInvalidPath MUST match fs.ErrInvalid")
+	}
+
+	err := wrapError(ErrNotFound, "parent record not found")
+
+	if err.Error() != "parent record not found" {
+		t.Fatal("unexpected message:", err.Error())
+	}
+
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("Wrapped error MUST match ErrNotFound and fs.ErrNotExist")
+	}
+}
+
+func TestStoreSentinelErrors(t *testing.T) {
+	store := initMoveStore(t, "file_sentinel_errors")
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDelete(docs)
+
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Fatal("expected ErrNotEmpty, found:", err)
+	}
+
+	err = store.RecordDeleteByID("")
+
+	if !errors.Is(err, ErrEmptyID) {
+		t.Fatal("expected ErrEmptyID, found:", err)
+	}
+
+	err = store.Mkdir("/docs")
+
+	if !errors.Is(err, ErrExists) || !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	err = store.WriteFile("/docs/readme.txt/note.txt", []byte("NOTE"))
+
+	if !errors.Is(err, ErrNotDirectory) {
+		t.Fatal("expected ErrNotDirectory, found:", err)
+	}
+
+	err = store.Move(docs.ID(), ROOT_ID, "a/b")
+
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Fatal("expected ErrInvalidPath, found:", err)
+	}
+
+	err = store.Move(docs.ID(), "missing", "docs")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	_, err = store.Stat("/missing.txt")
+
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+}
+
+func TestStoreStrictMode(t *testing.T) {
+	db := initDB(":memory:")
+
+	// A single connection, as each connection opens its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          "file_strict_mode",
+		AutomigrateEnabled: true,
+		StrictModeEnabled:  true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	testStrictMode(t, store)
+}
+
+func TestMemoryStoreStrictMode(t *testing.T) {
+	store := NewMemoryStore()
+	store.EnableStrictMode(true)
+
+	testStrictMode(t, store)
+}
+
+func testStrictMode(t *testing.T, store StoreInterface) {
+	record, err := store.RecordFindByID("missing", RecordQueryOptions{})
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	if record != nil {
+		t.Fatal("Record MUST be nil")
+	}
+
+	_, err = store.RecordFindByPath("/missing.txt", RecordQueryOptions{})
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	// The operations which look up records by themselves are not affected
+	err = store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/2026/report.txt", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err = store.RecordFindByPath("/docs/2026/report.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil {
+		t.Fatal("Record MUST NOT be nil")
+	}
+
+	err = store.Rename("/docs/2026/report.txt", "/docs/2026/renamed.txt")
+
+	if err != nil {
+		t.Fatal("Rename MUST NOT fail in strict mode:", err)
+	}
+
+	err = store.Move(record.ID(), ROOT_ID, "moved.txt")
+
+	if err != nil {
+		t.Fatal("Move MUST NOT fail in strict mode:", err)
+	}
+
+	writer, err := store.CreateWriter("/docs/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("STREAMED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("CreateWriter MUST NOT fail in strict mode:", err)
+	}
+
+	err = store.RecordSoftDelete(record)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordRestore(record)
+
+	if err != nil {
+		t.Fatal("RecordRestore MUST NOT fail in strict mode:", err)
+	}
+
+	if _, err = store.RecordFindByPath("/moved.txt", RecordQueryOptions{}); err != nil {
+		t.Fatal("The restored record MUST be found:", err)
+	}
+
+	_, err = store.FS().Stat("docs/missing.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	store.EnableStrictMode(false)
+
+	record, err = store.RecordFindByID("missing", RecordQueryOptions{})
+
+	if err != nil || record != nil {
+		t.Fatal("Find MUST return nil, nil when not in strict mode:", err)
+	}
+}
@@ -0,0 +1,477 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// This file contains a high level file API modeled on the os package.
+// The methods resolve the parent directories by path, and fill in
+// the parent ID, name, extension, size and path of the records
+// automatically. All the errors returned are of type *fs.PathError.
+
+// Create creates or truncates the named file, like os.Create.
+// The parent directory must exist.
+func (store *Store) Create(filePath string) (*Record, error) {
+	return operations{store}.create(filePath)
+}
+
+// Create creates or truncates the named file, like os.Create
+func (store *MemoryStore) Create(filePath string) (*Record, error) {
+	return operations{store}.create(filePath)
+}
+
+// MkdirAll creates the named directory, along with any missing parents,
+// like os.MkdirAll. If the directory already exists MkdirAll does nothing.
+func (store *Store) MkdirAll(dirPath string) error {
+	return operations{store}.mkdirAll(dirPath)
+}
+
+// MkdirAll creates the named directory, along with any missing parents,
+// like os.MkdirAll
+func (store *MemoryStore) MkdirAll(dirPath string) error {
+	return operations{store}.mkdirAll(dirPath)
+}
+
+// Mkdir creates the named directory, like os.Mkdir.
+// The parent directory must exist.
+func (store *Store) Mkdir(dirPath string) error {
+	return operations{store}.mkdir(dirPath)
+}
+
+// Mkdir creates the named directory, like os.Mkdir
+func (store *MemoryStore) Mkdir(dirPath string) error {
+	return operations{store}.mkdir(dirPath)
+}
+
+// OpenFile opens the named file and returns its record (with contents).
+// The flag supports os.O_CREATE, os.O_EXCL and os.O_TRUNC, which behave
+// as with os.OpenFile. The access mode flags are ignored.
+func (store *Store) OpenFile(filePath string, flag int) (*Record, error) {
+	return operations{store}.openFile(filePath, flag)
+}
+
+// OpenFile opens the named file and returns its record (with contents),
+// see Store.OpenFile
+func (store *MemoryStore) OpenFile(filePath string, flag int) (*Record, error) {
+	return operations{store}.openFile(filePath, flag)
+}
+
+// ReadFile reads the named file and returns its contents, like os.ReadFile
+func (store *Store) ReadFile(filePath string) ([]byte, error) {
+	return operations{store}.readFile(filePath)
+}
+
+// ReadFile reads the named file and returns its contents, like os.ReadFile
+func (store *MemoryStore) ReadFile(filePath string) ([]byte, error) {
+	return operations{store}.readFile(filePath)
+}
+
+// Remove removes the named file or empty directory, like os.Remove
+func (store *Store) Remove(filePath string) error {
+	return operations{store}.remove(filePath)
+}
+
+// Remove removes the named file or empty directory, like os.Remove
+func (store *MemoryStore) Remove(filePath string) error {
+	return operations{store}.remove(filePath)
+}
+
+// RemoveAll removes the named file or directory with everything it
+// contains (including soft deleted records), like os.RemoveAll.
+// If the path does not exist RemoveAll does nothing.
+func (store *Store) RemoveAll(filePath string) error {
+	return operations{store}.removeAll(filePath)
+}
+
+// RemoveAll removes the named file or directory with everything it
+// contains, like os.RemoveAll
+func (store *MemoryStore) RemoveAll(filePath string) error {
+	return operations{store}.removeAll(filePath)
+}
+
+// Rename renames (moves) the named file or directory, like os.Rename.
+// The new parent directory must exist, and unlike os.Rename an existing
+// file or directory at the new path is never replaced. The paths of all
+// the descendants of a directory are rewritten in the same transaction.
+func (store *Store) Rename(oldPath, newPath string) error {
+	return operations{store}.rename(oldPath, newPath)
+}
+
+// Rename renames (moves) the named file or directory, see Store.Rename
+func (store *MemoryStore) Rename(oldPath, newPath string) error {
+	return operations{store}.rename(oldPath, newPath)
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory,
+// like os.Stat. The Sys method of the fs.FileInfo returns the *Record.
+func (store *Store) Stat(filePath string) (fs.FileInfo, error) {
+	return operations{store}.stat(filePath)
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory,
+// like os.Stat
+func (store *MemoryStore) Stat(filePath string) (fs.FileInfo, error) {
+	return operations{store}.stat(filePath)
+}
+
+// WriteFile writes the data to the named file, creating it if necessary,
+// like os.WriteFile. The parent directory must exist.
+func (store *Store) WriteFile(filePath string, data []byte) error {
+	return operations{store}.writeFile(filePath, data)
+}
+
+// WriteFile writes the data to the named file, creating it if necessary,
+// like os.WriteFile
+func (store *MemoryStore) WriteFile(filePath string, data []byte) error {
+	return operations{store}.writeFile(filePath, data)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// create implements Create
+func (store operations) create(filePath string) (*Record, error) {
+	return store.OpenFile(filePath, os.O_CREATE|os.O_TRUNC)
+}
+
+// mkdirAll implements MkdirAll
+func (store operations) mkdirAll(dirPath string) error {
+	_, err := store.DirectoryEnsure(dirPath)
+	return err
+}
+
+// mkdir implements Mkdir
+func (store operations) mkdir(dirPath string) error {
+	dirPath, errPath := store.cleanPath("mkdir", dirPath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	existing, err := store.recordFindByPath(dirPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: dirPath, Err: err}
+	}
+
+	if existing != nil {
+		return &fs.PathError{Op: "mkdir", Path: dirPath, Err: ErrExists}
+	}
+
+	parent, err := store.parentDirectory("mkdir", dirPath)
+
+	if err != nil {
+		return err
+	}
+
+	directory := NewDirectory().
+		SetParentID(parent.ID()).
+		SetName(path.Base(dirPath)).
+		SetPath(dirPath)
+
+	err = store.recordCreate(directory, false)
+
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: dirPath, Err: err}
+	}
+
+	return nil
+}
+
+// openFile implements OpenFile
+func (store operations) openFile(filePath string, flag int) (*Record, error) {
+	filePath, errPath := store.cleanPath("open", filePath)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{WithContents: true})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: err}
+	}
+
+	if record != nil {
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, &fs.PathError{Op: "open", Path: filePath, Err: ErrExists}
+		}
+
+		if record.IsDirectory() {
+			return nil, &fs.PathError{Op: "open", Path: filePath, Err: errors.New("is a directory")}
+		}
+
+		if flag&os.O_TRUNC != 0 && record.Size() != "0" {
+			record.SetContents("").SetSize("0")
+
+			err = store.RecordUpdate(record)
+
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: filePath, Err: err}
+			}
+		}
+
+		return record, nil
+	}
+
+	if flag&os.O_CREATE == 0 {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: ErrNotFound}
+	}
+
+	return store.fileCreate("open", filePath, []byte{})
+}
+
+// readFile implements ReadFile
+func (store operations) readFile(filePath string) ([]byte, error) {
+	reader, err := store.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: filePath, Err: err}
+	}
+
+	return contents, nil
+}
+
+// remove implements Remove
+func (store operations) remove(filePath string) error {
+	filePath, errPath := store.cleanPath("remove", filePath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	if filePath == ROOT_PATH {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: fs.ErrPermission}
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: err}
+	}
+
+	if record == nil {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: ErrNotFound}
+	}
+
+	err = store.RecordDeleteByID(record.ID())
+
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: err}
+	}
+
+	return nil
+}
+
+// removeAll implements RemoveAll
+func (store operations) removeAll(filePath string) error {
+	filePath, errPath := store.cleanPath("remove", filePath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	if filePath == ROOT_PATH {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: fs.ErrPermission}
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: err}
+	}
+
+	if record == nil {
+		return nil
+	}
+
+	err = store.RecordDeleteRecursive(record)
+
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: filePath, Err: err}
+	}
+
+	return nil
+}
+
+// rename implements Rename
+func (store operations) rename(oldPath, newPath string) error {
+	oldPath, errPath := store.cleanPath("rename", oldPath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	newPath, errPath = store.cleanPath("rename", newPath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	if oldPath == ROOT_PATH || newPath == ROOT_PATH {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: fs.ErrPermission}
+	}
+
+	if oldPath == newPath {
+		return nil
+	}
+
+	err := store.transaction(func(txStore operations) error {
+		record, err := txStore.recordFindByPath(oldPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+		if err != nil {
+			return err
+		}
+
+		if record == nil {
+			return &fs.PathError{Op: "rename", Path: oldPath, Err: ErrNotFound}
+		}
+
+		parent, err := txStore.parentDirectory("rename", newPath)
+
+		if err != nil {
+			return err
+		}
+
+		return txStore.move("rename", record.ID(), parent.ID(), path.Base(newPath))
+	})
+
+	var pathErr *fs.PathError
+	if err != nil && !errors.As(err, &pathErr) {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: err}
+	}
+
+	return err
+}
+
+// stat implements Stat
+func (store operations) stat(filePath string) (fs.FileInfo, error) {
+	filePath, errPath := store.cleanPath("stat", filePath)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: filePath, Err: err}
+	}
+
+	if record == nil {
+		return nil, &fs.PathError{Op: "stat", Path: filePath, Err: ErrNotFound}
+	}
+
+	return newFileInfo(filePath, record), nil
+}
+
+// writeFile implements WriteFile
+func (store operations) writeFile(filePath string, data []byte) error {
+	filePath, errPath := store.cleanPath("write", filePath)
+
+	if errPath != nil {
+		return errPath
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: filePath, Err: err}
+	}
+
+	if record == nil {
+		_, err = store.fileCreate("write", filePath, data)
+		return err
+	}
+
+	if record.IsDirectory() {
+		return &fs.PathError{Op: "write", Path: filePath, Err: errors.New("is a directory")}
+	}
+
+	record.SetContentsBytes(data).SetSize(strconv.Itoa(len(data)))
+
+	err = store.RecordUpdate(record)
+
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: filePath, Err: err}
+	}
+
+	return nil
+}
+
+// cleanPath returns the shortest absolute form of the path, checked
+// against the path rules of the store, or a *fs.PathError for the op
+func (store operations) cleanPath(op string, filePath string) (string, error) {
+	cleanPath, err := store.settings().pathRules.CleanPath(filePath)
+
+	if err != nil {
+		return cleanPath, &fs.PathError{Op: op, Path: filePath, Err: err}
+	}
+
+	return cleanPath, nil
+}
+
+// fileCreate creates a new file with the data at the (clean) path
+func (store operations) fileCreate(op string, filePath string, data []byte) (*Record, error) {
+	parent, err := store.parentDirectory(op, filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	record := NewFile().
+		SetParentID(parent.ID()).
+		SetName(path.Base(filePath)).
+		SetExtension(pathExtension(filePath)).
+		SetSize(strconv.Itoa(len(data))).
+		SetContentsBytes(data).
+		SetPath(filePath)
+
+	err = store.recordCreate(record, false)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: filePath, Err: err}
+	}
+
+	return record, nil
+}
+
+// parentDirectory returns the parent directory of the (clean) path,
+// or a *fs.PathError if it does not exist or is not a directory
+func (store operations) parentDirectory(op string, filePath string) (*Record, error) {
+	parentPath := path.Dir(filePath)
+
+	parent, err := store.recordFindByPath(parentPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: filePath, Err: err}
+	}
+
+	if parent == nil {
+		return nil, &fs.PathError{Op: op, Path: filePath, Err: ErrNotFound}
+	}
+
+	if !parent.IsDirectory() {
+		return nil, &fs.PathError{Op: op, Path: filePath, Err: ErrNotDirectory}
+	}
+
+	return parent, nil
+}
+
+// pathExtension returns the extension of the file path without the dot
+func pathExtension(filePath string) string {
+	return strings.TrimPrefix(path.Ext(filePath), ".")
+}
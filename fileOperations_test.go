@@ -0,0 +1,342 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func initFileOperationsStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func TestStoreWriteFileAndReadFile(t *testing.T) {
+	store := initFileOperationsStore(t, "file_write_file")
+
+	err := store.Mkdir("/docs")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/report.txt", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("/docs/report.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil {
+		t.Fatal("File MUST NOT be nil")
+	}
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.ParentID() != docs.ID() {
+		t.Fatal("unexpected parent id:", record.ParentID())
+	}
+
+	if record.Name() != "report.txt" {
+		t.Fatal("unexpected name:", record.Name())
+	}
+
+	if record.Extension() != "txt" {
+		t.Fatal("unexpected extension:", record.Extension())
+	}
+
+	if record.Size() != "6" {
+		t.Fatal("unexpected size:", record.Size())
+	}
+
+	err = store.WriteFile("docs/report.txt", []byte("UPDATED REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/docs/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "UPDATED REPORT" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	info, err := store.Stat("/docs/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if info.Size() != int64(len("UPDATED REPORT")) {
+		t.Fatal("unexpected size:", info.Size())
+	}
+}
+
+func TestStoreWriteFileMissingParent(t *testing.T) {
+	store := initFileOperationsStore(t, "file_write_file_missing_parent")
+
+	err := store.WriteFile("/missing/report.txt", []byte("REPORT"))
+
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatal("expected *fs.PathError, found:", err)
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+}
+
+func TestStoreCreateAndOpenFile(t *testing.T) {
+	store := initFileOperationsStore(t, "file_create")
+
+	record, err := store.Create("/new.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.Size() != "0" || record.Path() != "/new.txt" {
+		t.Fatal("unexpected record:", record.Data())
+	}
+
+	_, err = store.OpenFile("/new.txt", os.O_CREATE|os.O_EXCL)
+
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, found:", err)
+	}
+
+	_, err = store.OpenFile("/other.txt", os.O_RDONLY)
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	err = store.WriteFile("/new.txt", []byte("DATA"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err = store.Create("/new.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.Size() != "0" || record.Contents() != "" {
+		t.Fatal("File MUST be truncated")
+	}
+}
+
+func TestStoreMkdirAll(t *testing.T) {
+	store := initFileOperationsStore(t, "file_mkdir_all")
+
+	err := store.MkdirAll("/uploads/2026/10")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Must be a no-op the second time
+	err = store.MkdirAll("/uploads/2026/10")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	year, err := store.RecordFindByPath("/uploads/2026", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	month, err := store.RecordFindByPath("/uploads/2026/10", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if year == nil || month == nil {
+		t.Fatal("Directories MUST be created")
+	}
+
+	if month.ParentID() != year.ID() || !month.IsDirectory() {
+		t.Fatal("unexpected directory:", month.Data())
+	}
+
+	err = store.Mkdir("/uploads")
+
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, found:", err)
+	}
+
+	err = store.WriteFile("/uploads/file.txt", []byte("FILE"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.MkdirAll("/uploads/file.txt/sub")
+
+	if err == nil {
+		t.Fatal("must return error as file.txt is not a directory")
+	}
+}
+
+func TestStoreRemoveAndRemoveAll(t *testing.T) {
+	store := initFileOperationsStore(t, "file_remove")
+
+	err := store.MkdirAll("/a/b")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/a/b/c.txt", []byte("C"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/a_b.txt", []byte("OTHER"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Remove("/a")
+
+	if err == nil {
+		t.Fatal("must return error as directory is not empty")
+	}
+
+	err = store.RemoveAll("/a")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, removed := range []string{"/a", "/a/b", "/a/b/c.txt"} {
+		_, err = store.Stat(removed)
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("expected fs.ErrNotExist for", removed, "found:", err)
+		}
+	}
+
+	_, err = store.Stat("/a_b.txt")
+
+	if err != nil {
+		t.Fatal("unrelated file MUST NOT be removed:", err)
+	}
+
+	err = store.Remove("/a_b.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RemoveAll("/missing")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStoreRename(t *testing.T) {
+	store := initFileOperationsStore(t, "file_rename")
+
+	err := store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/2026/report.txt", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Mkdir("/archive")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Rename("/docs", "/archive/documents")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/archive/documents/2026/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "REPORT" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	_, err = store.Stat("/docs")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	err = store.Rename("/archive/documents/2026/report.txt", "/archive/report.md")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("/archive/report.md", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil || record.Extension() != "md" || record.Name() != "report.md" {
+		t.Fatal("unexpected record:", record)
+	}
+
+	err = store.Rename("/archive", "/archive/documents/inside")
+
+	if err == nil {
+		t.Fatal("must return error as a directory cannot be moved into itself")
+	}
+
+	err = store.Rename("/archive/report.md", "/archive/documents")
+
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, found:", err)
+	}
+}
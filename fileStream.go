@@ -0,0 +1,549 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/uid"
+)
+
+// This file contains the streaming read and write handles. Streamed
+// contents are stored in fixed-size chunks in the chunk table, so that
+// a file never has to be held in memory as a whole. Files written
+// through the record contents (i.e. SetContents) have no chunks,
+// and are read from the contents column instead.
+
+// CreateWriter returns a writer for the named file, which is created
+// if it does not exist. The contents are written in chunks as they come
+// and replace the existing contents of the file only once the writer
+// is closed, together with the file size.
+//
+// The writer MUST be closed, otherwise nothing is written.
+func (store *Store) CreateWriter(filePath string) (io.WriteCloser, error) {
+	return operations{store}.createWriter(filePath)
+}
+
+// CreateWriter returns a writer to the named file, which is created
+// or replaced with the written contents when the writer is closed
+func (store *MemoryStore) CreateWriter(filePath string) (io.WriteCloser, error) {
+	return operations{store}.createWriter(filePath)
+}
+
+// Open opens the named file for reading. Only the chunk being read
+// is held in memory, so that large files can be streamed.
+func (store *Store) Open(filePath string) (io.ReadSeekCloser, error) {
+	return operations{store}.open(filePath)
+}
+
+// Open opens the named file for reading
+func (store *MemoryStore) Open(filePath string) (io.ReadSeekCloser, error) {
+	return operations{store}.open(filePath)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// createWriter implements CreateWriter
+func (store operations) createWriter(filePath string) (io.WriteCloser, error) {
+	filePath, errPath := store.cleanPath("create", filePath)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "create", Path: filePath, Err: err}
+	}
+
+	if record != nil && record.IsDirectory() {
+		return nil, &fs.PathError{Op: "create", Path: filePath, Err: errors.New("is a directory")}
+	}
+
+	if record == nil {
+		_, err = store.parentDirectory("create", filePath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return store.recordWriter(filePath), nil
+}
+
+// open implements Open
+func (store operations) open(filePath string) (io.ReadSeekCloser, error) {
+	filePath, errPath := store.cleanPath("open", filePath)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	record, err := store.recordFindByPath(filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: err}
+	}
+
+	if record == nil {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: ErrNotFound}
+	}
+
+	if record.IsDirectory() {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: errors.New("is a directory")}
+	}
+
+	reader, err := store.recordReader(record)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: err}
+	}
+
+	return reader, nil
+}
+
+// recordWriter returns a writer of the contents of the (clean) file path
+// in chunks, the file being created if it does not exist
+func (store *Store) recordWriter(filePath string) io.WriteCloser {
+	return &chunkWriter{
+		store:    store,
+		filePath: filePath,
+		uploadID: uid.HumanUid(),
+		hash:     sha256.New(),
+		buffer:   make([]byte, 0, store.chunkSize),
+	}
+}
+
+// recordReader returns a reader for the contents of the file record,
+// from the chunks if it has been streamed, or else from the contents column
+func (store *Store) recordReader(record *Record) (io.ReadSeekCloser, error) {
+	chunks, err := store.chunkList(record.ID())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chunks) > 0 {
+		return newChunkReader(store, record.ID(), chunks), nil
+	}
+
+	withContents, err := store.recordFindByID(record.ID(), RecordQueryOptions{
+		Columns: []string{COLUMN_ID, COLUMN_CONTENTS},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if withContents == nil {
+		return nil, ErrNotFound
+	}
+
+	return &contentsReader{Reader: bytes.NewReader([]byte(withContents.Contents()))}, nil
+}
+
+// chunkData returns the data of the chunk with the sequence number
+func (store *Store) chunkData(recordID string, sequence int) ([]byte, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.chunkTableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_DATA)).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(recordID), goqu.C(COLUMN_SEQUENCE).Eq(sequence)).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, errors.New("chunk " + strconv.Itoa(sequence) + " not found")
+	}
+
+	return []byte(rows[0][COLUMN_DATA]), nil
+}
+
+// chunkInsert stores a chunk of data for the record
+func (store *Store) chunkInsert(recordID string, sequence int, data []byte) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Insert(store.chunkTableName).
+		Prepared(true).
+		Rows(goqu.Record{
+			COLUMN_ID:         uid.HumanUid(),
+			COLUMN_RECORD_ID:  recordID,
+			COLUMN_SEQUENCE:   sequence,
+			COLUMN_SIZE:       len(data),
+			COLUMN_DATA:       data,
+			COLUMN_CREATED_AT: carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+		}).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// chunkList returns the sequence number, offset and size of the chunks
+// of the record, ordered by sequence number
+func (store *Store) chunkList(recordID string) ([]chunkInfo, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.chunkTableName).
+		Prepared(true).
+		Select(goqu.C(COLUMN_SEQUENCE), goqu.C(COLUMN_SIZE)).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(recordID)).
+		Order(goqu.C(COLUMN_SEQUENCE).Asc()).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]chunkInfo, 0, len(rows))
+	offset := int64(0)
+
+	for _, row := range rows {
+		sequence, err := strconv.Atoi(row[COLUMN_SEQUENCE])
+
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseInt(row[COLUMN_SIZE], 10, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, chunkInfo{sequence: sequence, offset: offset, size: size})
+		offset += size
+	}
+
+	return chunks, nil
+}
+
+// chunksDelete deletes all the chunks of the record
+func (store *Store) chunksDelete(recordID string) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Delete(store.chunkTableName).
+		Prepared(true).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(recordID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// chunksReassign moves all the chunks from one record ID to another
+func (store *Store) chunksReassign(fromRecordID string, toRecordID string) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.chunkTableName).
+		Prepared(true).
+		Set(goqu.Record{COLUMN_RECORD_ID: toRecordID}).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(fromRecordID)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// == CONTENTS READER ========================================================
+
+// contentsReader reads the contents of a file, which are not stored in chunks
+type contentsReader struct {
+	*bytes.Reader
+}
+
+func (reader *contentsReader) Close() error {
+	return nil
+}
+
+// == CHUNK READER ===========================================================
+
+type chunkInfo struct {
+	sequence int
+	offset   int64
+	size     int64
+}
+
+// chunkReader reads the contents of a file from its chunks,
+// loading one chunk at a time
+type chunkReader struct {
+	store    *Store
+	recordID string
+	chunks   []chunkInfo
+	size     int64
+	offset   int64
+	loaded   int
+	data     []byte
+	closed   bool
+}
+
+var _ io.ReadSeekCloser = (*chunkReader)(nil)
+
+func newChunkReader(store *Store, recordID string, chunks []chunkInfo) *chunkReader {
+	size := int64(0)
+
+	if len(chunks) > 0 {
+		last := chunks[len(chunks)-1]
+		size = last.offset + last.size
+	}
+
+	return &chunkReader{
+		store:    store,
+		recordID: recordID,
+		chunks:   chunks,
+		size:     size,
+		loaded:   -1,
+	}
+}
+
+func (reader *chunkReader) Close() error {
+	reader.closed = true
+	reader.data = nil
+	return nil
+}
+
+func (reader *chunkReader) Read(p []byte) (int, error) {
+	if reader.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if reader.offset >= reader.size {
+		return 0, io.EOF
+	}
+
+	index := sort.Search(len(reader.chunks), func(i int) bool {
+		return reader.chunks[i].offset+reader.chunks[i].size > reader.offset
+	})
+
+	chunk := reader.chunks[index]
+
+	if index != reader.loaded {
+		data, err := reader.store.chunkData(reader.recordID, chunk.sequence)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if int64(len(data)) != chunk.size {
+			return 0, errors.New("chunk " + strconv.Itoa(chunk.sequence) + " has unexpected size")
+		}
+
+		reader.data = data
+		reader.loaded = index
+	}
+
+	n := copy(p, reader.data[reader.offset-chunk.offset:])
+	reader.offset += int64(n)
+
+	return n, nil
+}
+
+func (reader *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	if reader.closed {
+		return 0, fs.ErrClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+
+	reader.offset = offset
+
+	return offset, nil
+}
+
+// == CHUNK WRITER ===========================================================
+
+// chunkWriter writes the contents of a file in chunks. Until the writer
+// is closed, the chunks belong to the upload ID instead of the file.
+type chunkWriter struct {
+	store    *Store
+	filePath string
+	uploadID string
+	hash     hash.Hash
+	buffer   []byte
+	sequence int
+	size     int64
+	closed   bool
+}
+
+var _ io.WriteCloser = (*chunkWriter)(nil)
+
+func (writer *chunkWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, fs.ErrClosed
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		n := min(writer.store.chunkSize-len(writer.buffer), len(p))
+
+		writer.buffer = append(writer.buffer, p[:n]...)
+		written += n
+		p = p[n:]
+
+		if len(writer.buffer) == writer.store.chunkSize {
+			err := writer.flush()
+
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close writes the remaining data, and replaces the contents
+// of the file with the written chunks in a single transaction
+func (writer *chunkWriter) Close() error {
+	if writer.closed {
+		return fs.ErrClosed
+	}
+
+	writer.closed = true
+
+	err := writer.flush()
+
+	if err == nil {
+		err = writer.store.transaction(writer.commit)
+	}
+
+	if err != nil {
+		if errDelete := writer.store.chunksDelete(writer.uploadID); errDelete != nil && writer.store.debugEnabled {
+			log.Println("chunk cleanup error: " + errDelete.Error())
+		}
+
+		return &fs.PathError{Op: "close", Path: writer.filePath, Err: err}
+	}
+
+	return nil
+}
+
+// commit creates or updates the file record, and hands it the uploaded chunks
+func (writer *chunkWriter) commit(txStore *Store) error {
+	record, err := txStore.recordFindByPath(writer.filePath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		record, err = operations{txStore}.fileCreate("create", writer.filePath, []byte{})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if record.IsDirectory() {
+		return errors.New("is a directory")
+	}
+
+	// Updating the contents deletes the previous chunks of the file. The
+	// hash is that of the written data, not of the empty contents.
+	record.SetContents("").SetSize(strconv.FormatInt(writer.size, 10))
+
+	// The version is kept once the record has the chunks
+	err = txStore.recordUpdate(record, hex.EncodeToString(writer.hash.Sum(nil)), nil)
+
+	if err != nil {
+		return err
+	}
+
+	err = txStore.chunksReassign(writer.uploadID, record.ID())
+
+	if err != nil || !txStore.versioningEnabled {
+		return err
+	}
+
+	return txStore.versionCreate(record.ID(), VersionOptions{})
+}
+
+// flush writes the buffered data as the next chunk
+func (writer *chunkWriter) flush() error {
+	if len(writer.buffer) == 0 {
+		return nil
+	}
+
+	err := writer.store.chunkInsert(writer.uploadID, writer.sequence, writer.buffer)
+
+	if err != nil {
+		return err
+	}
+
+	writer.hash.Write(writer.buffer)
+
+	writer.sequence++
+	writer.size += int64(len(writer.buffer))
+	writer.buffer = writer.buffer[:0]
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func initFileStreamStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+		ChunkSize:          4,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func TestStoreCreateWriterAndOpen(t *testing.T) {
+	store := initFileStreamStore(t, "file_stream")
+
+	writer, err := store.CreateWriter("/stream.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, part := range []string{"Hello", ", ", "streaming ", "world!"} {
+		_, err = writer.Write([]byte(part))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	// Nothing is visible before the writer is closed
+	_, err = store.Stat("/stream.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := "Hello, streaming world!"
+
+	record, err := store.RecordFindByPath("/stream.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil {
+		t.Fatal("File MUST NOT be nil")
+	}
+
+	if record.Size() != "23" {
+		t.Fatal("unexpected size:", record.Size())
+	}
+
+	chunks, err := store.chunkList(record.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 6 {
+		t.Fatal("unexpected number of chunks:", len(chunks))
+	}
+
+	reader, err := store.Open("/stream.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != expected {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	offset, err := reader.Seek(7, io.SeekStart)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if offset != 7 {
+		t.Fatal("unexpected offset:", offset)
+	}
+
+	part := make([]byte, 9)
+
+	_, err = io.ReadFull(reader, part)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(part) != "streaming" {
+		t.Fatal("unexpected contents:", string(part))
+	}
+
+	_, err = reader.Seek(-6, io.SeekEnd)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err = io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "world!" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreCreateWriterReplacesContents(t *testing.T) {
+	store := initFileStreamStore(t, "file_stream_replace")
+
+	err := store.WriteFile("/file.txt", []byte("OLD CONTENTS"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	writer, err := store.CreateWriter("/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = io.Copy(writer, strings.NewReader("NEW STREAMED CONTENTS"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "NEW STREAMED CONTENTS" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	// Writing the contents directly replaces the streamed chunks
+	err = store.WriteFile("/file.txt", []byte("DIRECT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err = fs.ReadFile(store.FS(), "file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "DIRECT" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	record, err := store.RecordFindByPath("/file.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunks, err := store.chunkList(record.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 0 {
+		t.Fatal("Chunks MUST be deleted, found:", len(chunks))
+	}
+}
+
+func TestStoreCreateWriterBinary(t *testing.T) {
+	store := initFileStreamStore(t, "file_stream_binary")
+
+	data := []byte{0, 1, 2, 255, 254, 0, 0, 10, 13, 0}
+
+	writer, err := store.CreateWriter("/binary.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write(data)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if !errors.Is(err, fs.ErrClosed) {
+		t.Fatal("expected fs.ErrClosed, found:", err)
+	}
+
+	contents, err := store.ReadFile("/binary.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !bytes.Equal(contents, data) {
+		t.Fatal("unexpected contents:", contents)
+	}
+}
+
+func TestStoreCreateWriterErrors(t *testing.T) {
+	store := initFileStreamStore(t, "file_stream_errors")
+
+	_, err := store.CreateWriter("/missing/file.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	err = store.Mkdir("/dir")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.CreateWriter("/dir")
+
+	if err == nil {
+		t.Fatal("must return error as /dir is a directory")
+	}
+
+	_, err = store.Open("/dir")
+
+	if err == nil {
+		t.Fatal("must return error as /dir is a directory")
+	}
+
+	_, err = store.Open("/missing.txt")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+}
+
+func TestStoreFileDeleteRemovesChunks(t *testing.T) {
+	store := initFileStreamStore(t, "file_stream_delete")
+
+	writer, err := store.CreateWriter("/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("SOME CHUNKED CONTENTS"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("/file.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Remove("/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunks, err := store.chunkList(record.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 0 {
+		t.Fatal("Chunks MUST be deleted, found:", len(chunks))
+	}
+}
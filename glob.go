@@ -0,0 +1,298 @@
+package sqlfilestore
+
+import (
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/samber/lo"
+)
+
+// Glob returns the live files and directories whose path matches the
+// pattern, in the order they are walked by Walk. The records have no
+// contents.
+//
+// The pattern has the syntax of path.Match ("*", "?", "[...]" and "\"
+// escaping), matched name by name against the path, plus "**" which
+// matches any number of names, including none ("/docs/**/*.pdf").
+// The pattern is turned into a LIKE condition, and a depth condition
+// when it has no "**", to let the database filter the records first.
+// The records are then matched with the exact semantics.
+func (store *Store) Glob(pattern string) ([]Record, error) {
+	return operations{store}.glob(pattern)
+}
+
+// Glob returns the live files and directories whose path matches the
+// pattern, in the order they are walked by Walk. See Store.Glob for the
+// syntax of the pattern.
+func (store *MemoryStore) Glob(pattern string) ([]Record, error) {
+	return operations{store}.glob(pattern)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// glob implements Glob
+func (store operations) glob(pattern string) ([]Record, error) {
+	pattern, err := globClean(pattern)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "glob", Path: pattern, Err: err}
+	}
+
+	candidates, err := store.globList(pattern)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "glob", Path: pattern, Err: err}
+	}
+
+	records := []Record{}
+
+	for _, record := range candidates {
+		if globMatch(pattern, record.Path()) {
+			records = append(records, record)
+		}
+	}
+
+	sortRecords(records)
+
+	return records, nil
+}
+
+// globList returns the live records which may match the (clean) pattern,
+// filtered by the database with the LIKE and depth conditions of the pattern
+func (store *Store) globList(pattern string) ([]Record, error) {
+	like, depth := globLike(pattern)
+
+	where := []exp.Expression{pathLike(like)}
+
+	if depth > 0 {
+		// The depth is the number of separators in the path
+		where = append(where, goqu.L("LENGTH(?) - LENGTH(REPLACE(?, ?, ''))", goqu.C(COLUMN_PATH), goqu.C(COLUMN_PATH), PATH_SEPARATOR).Eq(depth))
+	}
+
+	columns := lo.Map(fsMetadataColumns, func(column string, _ int) any {
+		return goqu.C(column)
+	})
+
+	sqlStr, params, errSql := store.recordQuery(RecordQueryOptions{}).
+		Prepared(true).
+		Select(columns...).
+		Where(where...).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) Record {
+		return *NewRecordFromExistingData(row)
+	}), nil
+}
+
+// likeEscapeCharacter is the escape character of the LIKE patterns,
+// a backslash having a special meaning in MySQL string literals
+const likeEscapeCharacter = "!"
+
+var likeEscaper = strings.NewReplacer(
+	likeEscapeCharacter, likeEscapeCharacter+likeEscapeCharacter,
+	"%", likeEscapeCharacter+"%",
+	"_", likeEscapeCharacter+"_",
+)
+
+// likeEscape escapes the LIKE wildcards in the string, so that it is
+// matched literally
+func likeEscape(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// pathLike returns the condition matching the path with the LIKE pattern,
+// escaped with likeEscape
+func pathLike(pattern string) exp.Expression {
+	return likeCondition(COLUMN_PATH, pattern)
+}
+
+// subtreeWhere returns the condition matching the paths of the descendants
+// of the directory. SUBSTR compares the prefix exactly, unlike LIKE where
+// "%" and "_" in the path would act as wildcards.
+func subtreeWhere(directoryPath string) exp.Expression {
+	prefix := strings.TrimSuffix(directoryPath, PATH_SEPARATOR) + PATH_SEPARATOR
+	return goqu.Func("SUBSTR", goqu.C(COLUMN_PATH), 1, utf8.RuneCountInString(prefix)).Eq(prefix)
+}
+
+// likeCondition returns the condition matching the column with the LIKE
+// pattern, escaped with likeEscape
+func likeCondition(column string, pattern string) exp.Expression {
+	return goqu.L("? LIKE ? ESCAPE '"+likeEscapeCharacter+"'", goqu.C(column), pattern)
+}
+
+// likeMatch returns whether the value matches the LIKE pattern, escaped
+// with likeEscape, the way likeCondition matches it (case sensitively)
+func likeMatch(pattern string, value string) bool {
+	expression := strings.Builder{}
+	expression.WriteString("^(?s)")
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '%':
+			expression.WriteString(".*")
+		case pattern[i] == '_':
+			expression.WriteString(".")
+		case pattern[i] == likeEscapeCharacter[0] && i+1 < len(pattern):
+			i++
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	expression.WriteString("$")
+
+	matched, err := regexp.MatchString(expression.String(), value)
+
+	return err == nil && matched
+}
+
+// globClean returns the absolute form of the pattern, checking its syntax
+func globClean(pattern string) (string, error) {
+	pattern = PATH_SEPARATOR + strings.Trim(strings.TrimSpace(pattern), PATH_SEPARATOR)
+
+	for _, name := range strings.Split(pattern, PATH_SEPARATOR) {
+		if name == "**" {
+			continue
+		}
+
+		if _, err := path.Match(name, ""); err != nil {
+			return pattern, err
+		}
+	}
+
+	return pattern, nil
+}
+
+// globLike returns the LIKE pattern matching (at least) the paths matched
+// by the glob pattern, and the depth of the paths matched, 0 if the
+// pattern has a "**" and matches paths of any depth
+func globLike(pattern string) (string, int) {
+	names := globNames(pattern)
+
+	if len(names) == 0 {
+		return ROOT_PATH, 0
+	}
+
+	like := strings.Builder{}
+	depth := len(names)
+
+	for i, name := range names {
+		if name == "**" {
+			// Matches any number of names, with their separators
+			like.WriteString("%")
+			depth = 0
+			continue
+		}
+
+		if i == 0 || names[i-1] != "**" {
+			like.WriteString(PATH_SEPARATOR)
+		}
+
+		like.WriteString(globNameLike(name))
+	}
+
+	return like.String(), depth
+}
+
+// globNameLike returns the LIKE pattern matching (at least) the names
+// matched by the path.Match pattern. A "*" does not match the separator,
+// unlike "%", the exact matching being left to globMatch.
+func globNameLike(name string) string {
+	like := strings.Builder{}
+
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '*':
+			like.WriteString("%")
+		case '?':
+			like.WriteString("_")
+		case '[':
+			// A character class matches a single character
+			for i++; i < len(name) && name[i] != ']'; i++ {
+				if name[i] == '\\' {
+					i++
+				}
+			}
+
+			like.WriteString("_")
+		case '\\':
+			if i+1 < len(name) {
+				i++
+			}
+
+			like.WriteString(likeEscape(name[i : i+1]))
+		default:
+			like.WriteString(likeEscape(name[i : i+1]))
+		}
+	}
+
+	return like.String()
+}
+
+// globMatch returns whether the path matches the (clean) glob pattern
+func globMatch(pattern string, filePath string) bool {
+	return globMatchNames(globNames(pattern), globNames(filePath))
+}
+
+// globNames returns the names of the (clean) pattern or path,
+// none for the root
+func globNames(pattern string) []string {
+	if pattern == ROOT_PATH {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimPrefix(pattern, PATH_SEPARATOR), PATH_SEPARATOR)
+}
+
+// globMatchNames matches the names of a path against the names of
+// a pattern, "**" matching any number of names
+func globMatchNames(patternNames []string, pathNames []string) bool {
+	for len(patternNames) > 0 {
+		if patternNames[0] == "**" {
+			// Matching none, one, or more of the names
+			for i := 0; i <= len(pathNames); i++ {
+				if globMatchNames(patternNames[1:], pathNames[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(pathNames) == 0 {
+			return false
+		}
+
+		matched, err := path.Match(patternNames[0], pathNames[0])
+
+		if err != nil || !matched {
+			return false
+		}
+
+		patternNames = patternNames[1:]
+		pathNames = pathNames[1:]
+	}
+
+	return len(pathNames) == 0
+}
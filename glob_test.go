@@ -0,0 +1,160 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestStoreGlob(t *testing.T) {
+	testGlob(t, initTxStore(t, "file_glob"))
+}
+
+func TestMemoryStoreGlob(t *testing.T) {
+	testGlob(t, NewMemoryStore())
+}
+
+func TestGlobLike(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		like    string
+		depth   int
+	}{
+		{"/", "/", 0},
+		{"/docs/*.pdf", "/docs/%.pdf", 2},
+		{"/docs/?.md", "/docs/_.md", 2},
+		{"/docs/[a-c\\]].md", "/docs/_.md", 2},
+		{"/100%_done/*", "/100!%!_done/%", 2},
+		{"/docs/\\*.md", "/docs/*.md", 2},
+		{"/docs/**/*.pdf", "/docs%%.pdf", 0},
+		{"/docs/**", "/docs%", 0},
+		{"/**", "%", 0},
+	} {
+		like, depth := globLike(test.pattern)
+
+		if like != test.like || depth != test.depth {
+			t.Fatal("expected:", test.like, test.depth, "found:", like, depth)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		filePath string
+		expected bool
+	}{
+		{"/", "/", true},
+		{"/*", "/", false},
+		{"/*", "/docs", true},
+		{"/*", "/docs/a.pdf", false},
+		{"/docs/**", "/docs", true},
+		{"/docs/**", "/docs/a/b", true},
+		{"/docs/**/*.pdf", "/docs/a.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b/c.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b/c.md", false},
+		{"/**", "/", true},
+		{"/docs/[ab].md", "/docs/c.md", false},
+	} {
+		if globMatch(test.pattern, test.filePath) != test.expected {
+			t.Fatal("expected", test.pattern, "to match", test.filePath, ":", test.expected)
+		}
+	}
+}
+
+func testGlob(t *testing.T, store StoreInterface) {
+	for _, dirPath := range []string{"/docs/2026/10", "/100%_done", "/100abcdone"} {
+		err := store.MkdirAll(dirPath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, filePath := range []string{
+		"/docs/a.pdf",
+		"/docs/b.md",
+		"/docs/c.pdf",
+		"/docs/2026/d.pdf",
+		"/docs/2026/10/e.pdf",
+		"/docs/*.md",
+		"/100%_done/report.md",
+		"/100abcdone/other.md",
+		"/docs/deleted.pdf",
+	} {
+		err := store.WriteFile(filePath, []byte(filePath))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	deleted, err := store.RecordFindByPath("/docs/deleted.pdf", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(deleted)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The wildcards of a prefix are matched literally
+	records, err := store.RecordList(RecordQueryOptions{PathStartsWith: "/100%_done/"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(records) != 1 || records[0].Path() != "/100%_done/report.md" {
+		t.Fatal("expected only /100%_done/report.md, found:", records)
+	}
+
+	glob := func(pattern string) string {
+		records, err := store.Glob(pattern)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		paths := []string{}
+
+		for _, record := range records {
+			if record.Contents() != "" {
+				t.Fatal("The records MUST have no contents:", record.Path())
+			}
+
+			paths = append(paths, record.Path())
+		}
+
+		return strings.Join(paths, " ")
+	}
+
+	for pattern, expected := range map[string]string{
+		"/docs/*.pdf":     "/docs/a.pdf /docs/c.pdf",
+		"docs/?.md":       "/docs/*.md /docs/b.md",
+		"/docs/[ab].*":    "/docs/a.pdf /docs/b.md",
+		"/docs/[^ab].pdf": "/docs/c.pdf",
+		"/docs/\\*.md":    "/docs/*.md",
+		"/docs/**/*.pdf":  "/docs/2026/10/e.pdf /docs/2026/d.pdf /docs/a.pdf /docs/c.pdf",
+		"/docs/*/*.pdf":   "/docs/2026/d.pdf",
+		"/100%_done/*":    "/100%_done/report.md",
+		"/*/*.md":         "/100%_done/report.md /100abcdone/other.md /docs/*.md /docs/b.md",
+		"/missing/*":      "",
+	} {
+		paths := glob(pattern)
+
+		if paths != expected {
+			t.Fatal("expected for", pattern, ":", expected, "found:", paths)
+		}
+	}
+
+	_, err = store.Glob("/docs/[a.pdf")
+
+	if !errors.Is(err, path.ErrBadPattern) {
+		t.Fatal("expected path.ErrBadPattern, found:", err)
+	}
+}
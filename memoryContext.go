@@ -0,0 +1,437 @@
+package sqlfilestore
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// This file contains the context aware variants of the in-memory store
+// methods. As the in-memory operations do not block, the context is only
+// checked before the operation starts.
+
+// AutoMigrateWithContext is AutoMigrate, with a context
+func (store *MemoryStore) AutoMigrateWithContext(ctx context.Context) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.AutoMigrate()
+}
+
+// ContentsMigrateToBinaryWithContext is ContentsMigrateToBinary, with a context
+func (store *MemoryStore) ContentsMigrateToBinaryWithContext(ctx context.Context) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.ContentsMigrateToBinary()
+}
+
+// ContentsMigrateToContentTableWithContext is ContentsMigrateToContentTable,
+// with a context
+func (store *MemoryStore) ContentsMigrateToContentTableWithContext(ctx context.Context) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.ContentsMigrateToContentTable()
+}
+
+// CopyWithContext is Copy, with a context
+func (store *MemoryStore) CopyWithContext(ctx context.Context, srcPath string, dstPath string, options CopyOptions) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Copy(srcPath, dstPath, options)
+}
+
+// CreateWithContext is Create, with a context
+func (store *MemoryStore) CreateWithContext(ctx context.Context, filePath string) (*Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.Create(filePath)
+}
+
+// CreateWriterWithContext is CreateWriter, with a context
+func (store *MemoryStore) CreateWriterWithContext(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.CreateWriter(filePath)
+}
+
+// DirectoryEnsureWithContext is DirectoryEnsure, with a context
+func (store *MemoryStore) DirectoryEnsureWithContext(ctx context.Context, dirPath string) (*Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.DirectoryEnsure(dirPath)
+}
+
+// GlobWithContext is Glob, with a context
+func (store *MemoryStore) GlobWithContext(ctx context.Context, pattern string) ([]Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.Glob(pattern)
+}
+
+// ListPageWithContext is ListPage, with a context
+func (store *MemoryStore) ListPageWithContext(ctx context.Context, options RecordQueryOptions, cursor string) ([]Record, string, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, "", err
+	}
+
+	return store.ListPage(options, cursor)
+}
+
+// ListRecursiveWithContext is ListRecursive, with a context
+func (store *MemoryStore) ListRecursiveWithContext(ctx context.Context, root string, options ListOptions) ([]Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.ListRecursive(root, options)
+}
+
+// MigrationsPendingWithContext is MigrationsPending, with a context
+func (store *MemoryStore) MigrationsPendingWithContext(ctx context.Context) ([]Migration, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.MigrationsPending()
+}
+
+// MkdirWithContext is Mkdir, with a context
+func (store *MemoryStore) MkdirWithContext(ctx context.Context, dirPath string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Mkdir(dirPath)
+}
+
+// MkdirAllWithContext is MkdirAll, with a context
+func (store *MemoryStore) MkdirAllWithContext(ctx context.Context, dirPath string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.MkdirAll(dirPath)
+}
+
+// MoveWithContext is Move, with a context
+func (store *MemoryStore) MoveWithContext(ctx context.Context, id string, newParentID string, newName string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Move(id, newParentID, newName)
+}
+
+// OpenWithContext is Open, with a context
+func (store *MemoryStore) OpenWithContext(ctx context.Context, filePath string) (io.ReadSeekCloser, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.Open(filePath)
+}
+
+// OpenFileWithContext is OpenFile, with a context
+func (store *MemoryStore) OpenFileWithContext(ctx context.Context, filePath string, flag int) (*Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.OpenFile(filePath, flag)
+}
+
+// ReadFileWithContext is ReadFile, with a context
+func (store *MemoryStore) ReadFileWithContext(ctx context.Context, filePath string) ([]byte, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.ReadFile(filePath)
+}
+
+// RecordCountWithContext is RecordCount, with a context
+func (store *MemoryStore) RecordCountWithContext(ctx context.Context, options RecordQueryOptions) (int64, error) {
+	if err := contextErr(ctx); err != nil {
+		return -1, err
+	}
+
+	return store.RecordCount(options)
+}
+
+// RecordCreateWithContext is RecordCreate, with a context
+func (store *MemoryStore) RecordCreateWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordCreate(record)
+}
+
+// RecordDeleteWithContext is RecordDelete, with a context
+func (store *MemoryStore) RecordDeleteWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordDelete(record)
+}
+
+// RecordDeleteByIDWithContext is RecordDeleteByID, with a context
+func (store *MemoryStore) RecordDeleteByIDWithContext(ctx context.Context, id string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordDeleteByID(id)
+}
+
+// RecordDeleteRecursiveWithContext is RecordDeleteRecursive, with a context
+func (store *MemoryStore) RecordDeleteRecursiveWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordDeleteRecursive(record)
+}
+
+// RecordFindByIDWithContext is RecordFindByID, with a context
+func (store *MemoryStore) RecordFindByIDWithContext(ctx context.Context, id string, options RecordQueryOptions) (*Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.RecordFindByID(id, options)
+}
+
+// RecordFindByPathWithContext is RecordFindByPath, with a context
+func (store *MemoryStore) RecordFindByPathWithContext(ctx context.Context, path string, options RecordQueryOptions) (*Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.RecordFindByPath(path, options)
+}
+
+// RecordListWithContext is RecordList, with a context
+func (store *MemoryStore) RecordListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return []Record{}, err
+	}
+
+	return store.RecordList(options)
+}
+
+// RecordLoadContentsWithContext is RecordLoadContents, with a context
+func (store *MemoryStore) RecordLoadContentsWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordLoadContents(record)
+}
+
+// RecordRecalculatePathWithContext is RecordRecalculatePath, with a context
+func (store *MemoryStore) RecordRecalculatePathWithContext(ctx context.Context, record *Record, parentRecord *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordRecalculatePath(record, parentRecord)
+}
+
+// RecordRestoreWithContext is RecordRestore, with a context
+func (store *MemoryStore) RecordRestoreWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordRestore(record)
+}
+
+// RecordRestoreByIDWithContext is RecordRestoreByID, with a context
+func (store *MemoryStore) RecordRestoreByIDWithContext(ctx context.Context, id string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordRestoreByID(id)
+}
+
+// RecordSoftDeleteWithContext is RecordSoftDelete, with a context
+func (store *MemoryStore) RecordSoftDeleteWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordSoftDelete(record)
+}
+
+// RecordSoftDeleteByIDWithContext is RecordSoftDeleteByID, with a context
+func (store *MemoryStore) RecordSoftDeleteByIDWithContext(ctx context.Context, id string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordSoftDeleteByID(id)
+}
+
+// RecordSoftDeleteRecursiveWithContext is RecordSoftDeleteRecursive, with a context
+func (store *MemoryStore) RecordSoftDeleteRecursiveWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordSoftDeleteRecursive(record)
+}
+
+// RecordUpdateWithContext is RecordUpdate, with a context
+func (store *MemoryStore) RecordUpdateWithContext(ctx context.Context, record *Record) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordUpdate(record)
+}
+
+// RecordUpdateVersionedWithContext is RecordUpdateVersioned, with a context
+func (store *MemoryStore) RecordUpdateVersionedWithContext(ctx context.Context, record *Record, options VersionOptions) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RecordUpdateVersioned(record, options)
+}
+
+// RemoveWithContext is Remove, with a context
+func (store *MemoryStore) RemoveWithContext(ctx context.Context, filePath string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Remove(filePath)
+}
+
+// RemoveAllWithContext is RemoveAll, with a context
+func (store *MemoryStore) RemoveAllWithContext(ctx context.Context, filePath string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.RemoveAll(filePath)
+}
+
+// RenameWithContext is Rename, with a context
+func (store *MemoryStore) RenameWithContext(ctx context.Context, oldPath string, newPath string) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Rename(oldPath, newPath)
+}
+
+// StatWithContext is Stat, with a context
+func (store *MemoryStore) StatWithContext(ctx context.Context, filePath string) (fs.FileInfo, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.Stat(filePath)
+}
+
+// TrashListWithContext is TrashList, with a context
+func (store *MemoryStore) TrashListWithContext(ctx context.Context, options RecordQueryOptions) ([]Record, error) {
+	if err := contextErr(ctx); err != nil {
+		return []Record{}, err
+	}
+
+	return store.TrashList(options)
+}
+
+// TrashPurgeWithContext is TrashPurge, with a context
+func (store *MemoryStore) TrashPurgeWithContext(ctx context.Context, olderThan time.Duration) (int, error) {
+	if err := contextErr(ctx); err != nil {
+		return 0, err
+	}
+
+	return store.TrashPurge(olderThan)
+}
+
+// VersionGetWithContext is VersionGet, with a context
+func (store *MemoryStore) VersionGetWithContext(ctx context.Context, id string, number int) (*Version, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.VersionGet(id, number)
+}
+
+// VersionListWithContext is VersionList, with a context
+func (store *MemoryStore) VersionListWithContext(ctx context.Context, id string) ([]Version, error) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	return store.VersionList(id)
+}
+
+// VersionPurgeWithContext is VersionPurge, with a context
+func (store *MemoryStore) VersionPurgeWithContext(ctx context.Context) (int, error) {
+	if err := contextErr(ctx); err != nil {
+		return 0, err
+	}
+
+	return store.VersionPurge()
+}
+
+// VersionRestoreWithContext is VersionRestore, with a context
+func (store *MemoryStore) VersionRestoreWithContext(ctx context.Context, id string, number int) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.VersionRestore(id, number)
+}
+
+// WalkWithContext is Walk, with a context
+func (store *MemoryStore) WalkWithContext(ctx context.Context, root string, fn WalkFunc) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.Walk(root, fn)
+}
+
+// WriteFileWithContext is WriteFile, with a context
+func (store *MemoryStore) WriteFileWithContext(ctx context.Context, filePath string, data []byte) error {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+
+	return store.WriteFile(filePath, data)
+}
+
+// contextErr returns the error of the context, a nil context being
+// taken as the background context, as by the database store
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,441 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+// Migration is a versioned change of the schema of the store tables.
+// The versions applied are kept in the schema table of the store
+// (the name of the record table, suffixed with "_schema").
+type Migration struct {
+	Version int
+	Name    string
+
+	up func(store *Store) error
+}
+
+// migrations returns the migrations of the store, in the order of their
+// versions. New migrations are appended, existing ones are never changed.
+// The same holds for the SQL creating the tables, which the migrations
+// call: a new column is added by a new migration with columnAdd, never to
+// the create table SQL, so that a fresh schema and an upgraded one match.
+func (store *Store) migrations() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "create the record and chunk tables",
+			up: func(store *Store) error {
+				_, err := store.executeSql(store.sqlTableCreate())
+
+				if err != nil {
+					return err
+				}
+
+				_, err = store.executeSql(store.sqlChunkTableCreate())
+
+				return err
+			},
+		},
+		{
+			Version: 2,
+			Name:    "create the indexes on parent_id, path, type and deleted_at",
+			up: func(store *Store) error {
+				for _, column := range []string{COLUMN_PARENT_ID, COLUMN_PATH, COLUMN_TYPE, COLUMN_DELETED_AT} {
+					err := store.indexCreate(store.tableName, columnIndexName(store.tableName, column), store.sqlIndexCreate(store.tableName, column))
+
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			Version: 3,
+			Name:    "create the index on the record_id of the chunks",
+			up: func(store *Store) error {
+				return store.indexCreate(store.chunkTableName, columnIndexName(store.chunkTableName, COLUMN_RECORD_ID), store.sqlIndexCreate(store.chunkTableName, COLUMN_RECORD_ID))
+			},
+		},
+		{
+			Version: 4,
+			Name:    "create the unique index on the parent_id and name of the live records",
+			up: func(store *Store) error {
+				sql := store.sqlUniqueIndexCreate()
+
+				if sql == "" {
+					return nil // not supported, uniqueness is enforced by the store
+				}
+
+				_, err := store.executeSql(sql)
+
+				return err
+			},
+		},
+		{
+			Version: 5,
+			Name:    "create the content table and add the content_id column",
+			up: func(store *Store) error {
+				_, err := store.executeSql(store.sqlContentTableCreate())
+
+				if err != nil {
+					return err
+				}
+
+				return store.columnAdd(store.tableName, sb.Column{
+					Name:   COLUMN_CONTENT_ID,
+					Type:   sb.COLUMN_TYPE_STRING,
+					Length: 40,
+				})
+			},
+		},
+		{
+			Version: 6,
+			Name:    "add the hash columns, the ref_count column and the unique index on the hash of the contents",
+			up: func(store *Store) error {
+				err := store.columnAdd(store.tableName, sb.Column{
+					Name:   COLUMN_HASH,
+					Type:   sb.COLUMN_TYPE_STRING,
+					Length: 64,
+				})
+
+				if err != nil {
+					return err
+				}
+
+				// The content rows written before have no hash
+				err = store.columnAdd(store.contentTableName, sb.Column{
+					Name:     COLUMN_HASH,
+					Type:     sb.COLUMN_TYPE_STRING,
+					Length:   64,
+					Nullable: true,
+				})
+
+				if err != nil {
+					return err
+				}
+
+				err = store.columnAdd(store.contentTableName, sb.Column{
+					Name:    COLUMN_REF_COUNT,
+					Type:    sb.COLUMN_TYPE_INTEGER,
+					Default: "1",
+				})
+
+				if err != nil {
+					return err
+				}
+
+				return store.indexCreate(store.contentTableName, store.contentHashIndexName(), store.sqlContentHashIndexCreate())
+			},
+		},
+		{
+			Version: 7,
+			Name:    "create the version table and the index on its record_id",
+			up: func(store *Store) error {
+				_, err := store.executeSql(store.sqlVersionTableCreate())
+
+				if err != nil {
+					return err
+				}
+
+				return store.indexCreate(store.versionTableName, columnIndexName(store.versionTableName, COLUMN_RECORD_ID), store.sqlIndexCreate(store.versionTableName, COLUMN_RECORD_ID))
+			},
+		},
+		{
+			Version: 8,
+			Name:    "add the codec column to the record, content and version tables",
+			up: func(store *Store) error {
+				for _, tableName := range []string{store.tableName, store.contentTableName, store.versionTableName} {
+					err := store.columnAdd(tableName, sb.Column{
+						Name:   COLUMN_CODEC,
+						Type:   sb.COLUMN_TYPE_STRING,
+						Length: 10,
+					})
+
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			Version: 9,
+			Name:    "backfill the hashes of the content rows and of the records, merging the content rows with the same contents",
+			up: func(store *Store) error {
+				return store.hashesBackfill()
+			},
+		},
+	}
+}
+
+// MigrationsPending returns the migrations which are not applied yet,
+// without applying them. AutoMigrate applies them.
+func (store *Store) MigrationsPending() ([]Migration, error) {
+	applied, err := store.migrationsApplied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+
+	for _, migration := range store.migrations() {
+		if !slices.Contains(applied, migration.Version) {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// == PRIVATE METHODS ========================================================
+
+// migrate creates the schema table, if missing, and applies the pending
+// migrations. Each migration is applied in a transaction, together with
+// the saving of its version (MySQL commits schema changes implicitly).
+func (store *Store) migrate() error {
+	_, err := store.executeSql(store.sqlSchemaTableCreate())
+
+	if err != nil {
+		return err
+	}
+
+	pending, err := store.MigrationsPending()
+
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		err = store.transaction(func(txStore *Store) error {
+			err := migration.up(txStore)
+
+			if err != nil {
+				return err
+			}
+
+			return txStore.migrationSave(migration)
+		})
+
+		if err != nil {
+			return errors.New("migration " + strconv.Itoa(migration.Version) + " (" + migration.Name + ") failed: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// migrationsApplied returns the versions of the applied migrations,
+// none if the schema table does not exist yet
+func (store *Store) migrationsApplied() ([]int, error) {
+	exists, err := store.tableExists(store.schemaTableName())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return []int{}, nil
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.schemaTableName()).
+		Prepared(true).
+		Select(COLUMN_VERSION).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []int{}
+
+	for _, row := range rows {
+		version, err := strconv.Atoi(row[COLUMN_VERSION])
+
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// migrationSave saves the migration as applied
+func (store *Store) migrationSave(migration Migration) error {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Insert(store.schemaTableName()).
+		Prepared(true).
+		Rows(goqu.Record{
+			COLUMN_VERSION:    migration.Version,
+			COLUMN_NAME:       migration.Name,
+			COLUMN_APPLIED_AT: carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+		}).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// columnAdd adds the column to the table, unless it exists already, so
+// that the migrations adding columns are safe on the tables created with
+// the columns in place. The existing rows get the default of the column,
+// which is required for a column which is not nullable.
+func (store *Store) columnAdd(tableName string, column sb.Column) error {
+	columns, err := store.tableColumns(tableName)
+
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(columns, column.Name) {
+		return nil
+	}
+
+	sql, err := sb.NewBuilder(store.dbDriverName).TableColumnAdd(tableName, column)
+
+	if err != nil {
+		return err
+	}
+
+	if !column.Nullable || column.Default != "" {
+		textual := column.Type == sb.COLUMN_TYPE_STRING || column.Type == sb.COLUMN_TYPE_TEXT || column.Type == sb.COLUMN_TYPE_LONGTEXT
+
+		if column.Default == "" && !textual {
+			return errors.New("column " + column.Name + " is not nullable and has no default")
+		}
+
+		// The default is not rendered by the builder
+		sql = strings.TrimSuffix(sql, ";") + " DEFAULT '" + strings.ReplaceAll(column.Default, "'", "''") + "';"
+	}
+
+	if store.debugEnabled {
+		log.Println(sql)
+	}
+
+	_, err = store.executeSql(sql)
+
+	return err
+}
+
+// tableColumns returns the names of the columns of the table
+func (store *Store) tableColumns(tableName string) ([]string, error) {
+	sqlStr, _, errSql := goqu.Dialect(store.dbDriverName).
+		From(tableName).
+		Where(goqu.L("1 = 0")).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	rows, err := store.database().QueryContext(store.contextOrBackground(), sqlStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return rows.Columns()
+}
+
+// tableExists returns whether the table exists
+func (store *Store) tableExists(tableName string) (bool, error) {
+	var sqlStr string
+
+	switch store.dbDriverName {
+	case sb.DIALECT_SQLITE:
+		sqlStr = `SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`
+	case sb.DIALECT_MYSQL:
+		sqlStr = `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	case sb.DIALECT_POSTGRES:
+		sqlStr = `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	default:
+		return false, errors.New("checking if a table exists is not supported for driver " + store.dbDriverName)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, tableName)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(rows) > 0, nil
+}
+
+// indexExists returns whether the table has an index with the name
+func (store *Store) indexExists(tableName string, indexName string) (bool, error) {
+	var sqlStr string
+
+	switch store.dbDriverName {
+	case sb.DIALECT_SQLITE:
+		sqlStr = `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?`
+	case sb.DIALECT_MYSQL:
+		sqlStr = `SELECT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`
+	case sb.DIALECT_POSTGRES:
+		sqlStr = `SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2`
+	default:
+		return false, errors.New("checking if an index exists is not supported for driver " + store.dbDriverName)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, tableName, indexName)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(rows) > 0, nil
+}
+
+// indexCreate executes the SQL creating the index with the name on the
+// table, unless the index exists already, as MySQL does not support
+// CREATE INDEX IF NOT EXISTS
+func (store *Store) indexCreate(tableName string, indexName string, sqlStr string) error {
+	exists, err := store.indexExists(tableName, indexName)
+
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = store.executeSql(sqlStr)
+
+	return err
+}
+
+// schemaTableName returns the name of the table keeping the applied migrations
+func (store *Store) schemaTableName() string {
+	return store.tableName + "_schema"
+}
@@ -0,0 +1,192 @@
+package sqlfilestore
+
+import (
+	"testing"
+
+	"github.com/gouniverse/sb"
+)
+
+func TestStoreMigrationsPending(t *testing.T) {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:        db,
+		TableName: "file_migrations_pending",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	pending, err := store.MigrationsPending()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(pending) != len(store.migrations()) {
+		t.Fatal("All the migrations MUST be pending, found:", len(pending))
+	}
+
+	for i, migration := range pending {
+		if migration.Version != i+1 || migration.Name == "" {
+			t.Fatal("unexpected migration:", migration.Version, migration.Name)
+		}
+	}
+
+	// Reporting the pending migrations does not apply anything
+	for _, tableName := range []string{"file_migrations_pending", "file_migrations_pending_schema"} {
+		exists, err := store.tableExists(tableName)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if exists {
+			t.Fatal("Table MUST NOT exist:", tableName)
+		}
+	}
+
+	err = store.AutoMigrate()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	pending, err = store.MigrationsPending()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatal("No migrations MUST be pending, found:", len(pending))
+	}
+
+	indexes, err := store.selectToMapString(`SELECT name FROM sqlite_master WHERE type = 'index' AND name LIKE 'file_migrations_pending%'`)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	indexNames := map[string]bool{}
+
+	for _, index := range indexes {
+		indexNames[index["name"]] = true
+	}
+
+	for _, indexName := range []string{
+		"file_migrations_pending_parent_id_idx",
+		"file_migrations_pending_path_idx",
+		"file_migrations_pending_type_idx",
+		"file_migrations_pending_deleted_at_idx",
+		"file_migrations_pending_chunk_record_id_idx",
+		"file_migrations_pending_parent_id_name_unique",
+	} {
+		if !indexNames[indexName] {
+			t.Fatal("Index MUST exist:", indexName)
+		}
+	}
+
+	// Migrating again is a no-op
+	err = store.AutoMigrate()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStoreMigrateExistingTable(t *testing.T) {
+	db := initDB(":memory:")
+	db.SetMaxOpenConns(1)
+
+	store, err := NewStore(NewStoreOptions{
+		DB:        db,
+		TableName: "file_migrate_existing",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// A table created before the migrations were versioned
+	_, err = store.executeSql(store.sqlTableCreate())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordCreate(NewDirectory().SetID(ROOT_ID).SetPath(ROOT_PATH).SetName("root").SetParentID("-1"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.AutoMigrate()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("The existing records MUST be kept, found:", count)
+	}
+
+	pending, err := store.MigrationsPending()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(pending) != 0 {
+		t.Fatal("No migrations MUST be pending, found:", len(pending))
+	}
+}
+
+func TestStoreColumnAdd(t *testing.T) {
+	store := initMoveStore(t, "file_column_add")
+
+	column := sb.Column{
+		Name:    "note",
+		Type:    sb.COLUMN_TYPE_STRING,
+		Length:  100,
+		Default: "none",
+	}
+
+	// Adding the column twice is a no-op
+	for i := 0; i < 2; i++ {
+		err := store.columnAdd(store.tableName, column)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	rows, err := store.selectToMapString(`SELECT "note" FROM "file_column_add"`)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(rows) == 0 {
+		t.Fatal("The existing rows MUST be kept")
+	}
+
+	for _, row := range rows {
+		if row["note"] != "none" {
+			t.Fatal("The existing rows MUST get the default, found:", row["note"])
+		}
+	}
+
+	err = store.columnAdd(store.tableName, sb.Column{Name: "counter", Type: sb.COLUMN_TYPE_INTEGER})
+
+	if err == nil {
+		t.Fatal("A column which is not nullable MUST have a default")
+	}
+}
@@ -0,0 +1,233 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gouniverse/sb"
+)
+
+// Move moves the record with the ID into the new parent directory,
+// under the new name. Moving a directory moves everything it contains.
+//
+// The target is validated first: the new parent must be a directory,
+// a directory cannot be moved into itself or one of its descendants,
+// and no other record may exist at the new path. The record and all
+// its descendants are then updated in a single transaction.
+func (store *Store) Move(id string, newParentID string, newName string) error {
+	return operations{store}.move("move", id, newParentID, newName)
+}
+
+// Move moves the record with the ID into the new parent directory,
+// under the new name, see Store.Move
+func (store *MemoryStore) Move(id string, newParentID string, newName string) error {
+	return operations{store}.move("move", id, newParentID, newName)
+}
+
+// RecordRecalculatePath sets the path of the record from the path of its
+// parent, and recalculates the paths of all its descendants, recursively.
+// All the updates are made in a single transaction.
+func (store *Store) RecordRecalculatePath(record *Record, parentRecord *Record) error {
+	return operations{store}.recordRecalculatePath(record, parentRecord)
+}
+
+// RecordRecalculatePath sets the path of the record from the path of its
+// parent, and recalculates the paths of all its descendants, recursively
+func (store *MemoryStore) RecordRecalculatePath(record *Record, parentRecord *Record) error {
+	return operations{store}.recordRecalculatePath(record, parentRecord)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// move implements Move, the op being used in the returned *fs.PathError
+func (store operations) move(op string, id string, newParentID string, newName string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+
+	if newParentID == "" {
+		return errors.New("parent id is empty")
+	}
+
+	if err := store.settings().pathRules.ValidateName(newName); err != nil {
+		return &fs.PathError{Op: op, Path: newName, Err: err}
+	}
+
+	if id == ROOT_ID {
+		return &fs.PathError{Op: op, Path: ROOT_PATH, Err: fs.ErrPermission}
+	}
+
+	return store.transaction(func(txStore operations) error {
+		record, err := txStore.recordFindByID(id, RecordQueryOptions{Columns: fsMetadataColumns})
+
+		if err != nil {
+			return err
+		}
+
+		if record == nil {
+			return &fs.PathError{Op: op, Path: id, Err: ErrNotFound}
+		}
+
+		parent, err := txStore.recordFindByID(newParentID, RecordQueryOptions{Columns: fsMetadataColumns})
+
+		if err != nil {
+			return err
+		}
+
+		if parent == nil {
+			return &fs.PathError{Op: op, Path: record.Path(), Err: wrapError(ErrNotFound, "parent record not found")}
+		}
+
+		if !parent.IsDirectory() {
+			return &fs.PathError{Op: op, Path: parent.Path(), Err: ErrNotDirectory}
+		}
+
+		oldPath := record.Path()
+		newPath := strings.TrimSuffix(parent.Path(), PATH_SEPARATOR) + PATH_SEPARATOR + newName
+
+		if newPath == oldPath {
+			return nil
+		}
+
+		if record.IsDirectory() && (parent.ID() == record.ID() || strings.HasPrefix(parent.Path()+PATH_SEPARATOR, oldPath+PATH_SEPARATOR)) {
+			return &fs.PathError{Op: op, Path: oldPath, Err: errors.New("cannot move a directory into itself")}
+		}
+
+		existing, err := txStore.recordFindByPath(newPath, RecordQueryOptions{Columns: []string{COLUMN_ID}})
+
+		if err != nil {
+			return err
+		}
+
+		if existing != nil && existing.ID() != record.ID() {
+			return &fs.PathError{Op: op, Path: newPath, Err: ErrExists}
+		}
+
+		record.
+			SetParentID(parent.ID()).
+			SetName(newName).
+			SetPath(newPath)
+
+		if record.IsFile() {
+			record.SetExtension(pathExtension(newName))
+		}
+
+		err = txStore.RecordUpdate(record)
+
+		if err != nil {
+			return err
+		}
+
+		if !record.IsDirectory() {
+			return nil
+		}
+
+		return txStore.descendantsPathRewrite(oldPath, newPath)
+	})
+}
+
+// descendantsPathRewrite replaces the old path prefix of all the
+// descendants of a directory (soft deleted included) with the new one,
+// using a single set-based UPDATE statement
+func (store *Store) descendantsPathRewrite(oldPath string, newPath string) error {
+	sqlStr, params, errSql := store.sqlDescendantsPathRewrite(oldPath, newPath)
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// sqlDescendantsPathRewrite returns the UPDATE statement replacing the old
+// path prefix of the descendants of a directory with the new one
+func (store *Store) sqlDescendantsPathRewrite(oldPath string, newPath string) (string, []any, error) {
+	oldPrefix := strings.TrimSuffix(oldPath, PATH_SEPARATOR) + PATH_SEPARATOR
+	newPrefix := strings.TrimSuffix(newPath, PATH_SEPARATOR) + PATH_SEPARATOR
+	oldPrefixLength := utf8.RuneCountInString(oldPrefix)
+
+	// The rest of the path, after the old prefix
+	rest := goqu.Func("SUBSTR", goqu.C(COLUMN_PATH), oldPrefixLength+1)
+
+	// || is OR on MySQL. Elsewhere the prefix is cast, as PostgreSQL cannot
+	// tell the type of a parameter of CONCAT, which SQLite lacks before 3.44.
+	var path exp.Expression = goqu.L("CAST(? AS TEXT) || ?", newPrefix, rest)
+
+	if store.dbDriverName == sb.DIALECT_MYSQL {
+		path = goqu.Func("CONCAT", newPrefix, rest)
+	}
+
+	return goqu.Dialect(store.dbDriverName).
+		Update(store.tableName).
+		Prepared(true).
+		Set(goqu.Record{COLUMN_PATH: path}).
+		Where(subtreeWhere(oldPath)).
+		ToSQL()
+}
+
+// recordRecalculatePath implements RecordRecalculatePath
+func (store operations) recordRecalculatePath(record *Record, parentRecord *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	return store.transaction(func(txStore operations) error {
+		return txStore.pathRecalculate(record, parentRecord)
+	})
+}
+
+// pathRecalculate implements RecordRecalculatePath,
+// and must be called in a transaction
+func (store operations) pathRecalculate(record *Record, parentRecord *Record) error {
+	if parentRecord == nil {
+		var err error
+		parentRecord, err = store.recordFindByID(record.ParentID(), RecordQueryOptions{Columns: []string{"id", "path"}})
+
+		if err != nil {
+			return err
+		}
+
+		if parentRecord == nil {
+			return wrapError(ErrNotFound, "parent record not found")
+		}
+	}
+
+	record.SetPath(parentRecord.Path() + PATH_SEPARATOR + record.Name())
+
+	err := store.RecordUpdate(record)
+
+	if err != nil {
+		return err
+	}
+
+	children, err := store.RecordList(RecordQueryOptions{
+		ParentID:        record.ID(),
+		Columns:         []string{"id", "name", "path"},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		err = store.pathRecalculate(&child, record)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
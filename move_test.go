@@ -0,0 +1,240 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/gouniverse/sb"
+)
+
+func initMoveStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, dirPath := range []string{"/docs/2026/10", "/a_b/sub", "/axb", "/archive"} {
+		err = store.MkdirAll(dirPath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, filePath := range []string{"/docs/readme.txt", "/docs/2026/10/report.txt", "/a_b/sub/file.txt", "/axb/other.txt"} {
+		err = store.WriteFile(filePath, []byte(filePath))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return store
+}
+
+func TestStoreMove(t *testing.T) {
+	store := initMoveStore(t, "file_move")
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	archive, err := store.RecordFindByPath("/archive", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Move(docs.ID(), archive.ID(), "documents")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	moved, err := store.RecordFindByID(docs.ID(), RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if moved.Path() != "/archive/documents" || moved.Name() != "documents" || moved.ParentID() != archive.ID() {
+		t.Fatal("unexpected record:", moved.Data())
+	}
+
+	for _, filePath := range []string{"/archive/documents/readme.txt", "/archive/documents/2026/10/report.txt"} {
+		contents, err := store.ReadFile(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(contents) == 0 {
+			t.Fatal("unexpected empty contents for", filePath)
+		}
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST remain under /docs, found:", count)
+	}
+}
+
+func TestStoreMoveValidation(t *testing.T) {
+	store := initMoveStore(t, "file_move_validation")
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	month, err := store.RecordFindByPath("/docs/2026/10", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	readme, err := store.RecordFindByPath("/docs/readme.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.Move(docs.ID(), month.ID(), "docs")
+
+	if err == nil {
+		t.Fatal("must return error as a directory cannot be moved into its descendant")
+	}
+
+	err = store.Move(docs.ID(), docs.ID(), "docs")
+
+	if err == nil {
+		t.Fatal("must return error as a directory cannot be moved into itself")
+	}
+
+	err = store.Move(month.ID(), docs.ID(), "readme.txt")
+
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatal("expected fs.ErrExist, found:", err)
+	}
+
+	err = store.Move(month.ID(), readme.ID(), "10")
+
+	if err == nil {
+		t.Fatal("must return error as the parent is not a directory")
+	}
+
+	err = store.Move(month.ID(), docs.ID(), "a/b")
+
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatal("expected fs.ErrInvalid, found:", err)
+	}
+
+	err = store.Move(ROOT_ID, docs.ID(), "root")
+
+	if err == nil {
+		t.Fatal("must return error as the root cannot be moved")
+	}
+
+	err = store.Move("missing", docs.ID(), "missing")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist, found:", err)
+	}
+
+	// Nothing must have changed
+	_, err = store.Stat("/docs/2026/10/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStoreRenameDoesNotTouchSimilarPaths(t *testing.T) {
+	store := initMoveStore(t, "file_rename_similar")
+
+	err := store.Rename("/a_b", "/renamed")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/renamed/sub/file.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/axb/other.txt")
+
+	if err != nil {
+		t.Fatal("unrelated file MUST NOT be moved:", err)
+	}
+}
+
+func TestStoreRecordRecalculatePath(t *testing.T) {
+	store := initMoveStore(t, "file_recalculate_path")
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	docs.SetName("documents")
+
+	err = store.RecordRecalculatePath(docs, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/documents/2026/10/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStoreDescendantsPathRewriteSQL(t *testing.T) {
+	for _, test := range []struct {
+		driverName string
+		expected   string
+	}{
+		{sb.DIALECT_MYSQL, `"path"=CONCAT(?, SUBSTR("path", ?))`},
+		{sb.DIALECT_POSTGRES, `"path"=CAST(? AS TEXT) || SUBSTR("path", ?)`},
+		{sb.DIALECT_SQLITE, `"path"=CAST(? AS TEXT) || SUBSTR("path", ?)`},
+	} {
+		store := &Store{tableName: "file_path_rewrite", dbDriverName: test.driverName}
+
+		sqlStr, params, err := store.sqlDescendantsPathRewrite("/docs", "/archive/docs")
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if !strings.Contains(sqlStr, test.expected) {
+			t.Fatal("expected for", test.driverName, "the path to be set with:", test.expected, "found:", sqlStr)
+		}
+
+		if len(params) != 5 || params[0] != "/archive/docs/" || params[4] != "/docs/" {
+			t.Fatal("unexpected params:", params)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package sqlfilestore
+
+import (
+	"io"
+)
+
+// This file contains the foundation of the operations shared by the Store
+// and the MemoryStore. The file operations, copies, moves, walks, trash and
+// versioning are implemented once, by the operations type, on top of the
+// record primitives each store implements for its storage (recordStorage).
+// The public methods of both stores call them.
+
+// storeSettings are the settings of a store, shared by both stores
+type storeSettings struct {
+	debugEnabled      bool
+	strictModeEnabled bool
+	autoSuffixEnabled bool
+	pathRules         PathRules
+	cursorSecret      []byte
+	versioningEnabled bool
+	versionRetention  VersionRetention
+}
+
+// settings returns the settings, to the operations of the store
+// embedding them
+func (settings *storeSettings) settings() *storeSettings {
+	return settings
+}
+
+// recordStorage is implemented by the stores, with the primitives
+// the operations are implemented on
+type recordStorage interface {
+	StoreInterface
+
+	// settings returns the settings of the store
+	settings() *storeSettings
+
+	// transactionRun executes the function with a copy of the store bound
+	// to a transaction, joining the transaction the store is bound to
+	transactionRun(fn func(txStorage recordStorage) error) error
+
+	// inTransaction returns whether the store is bound to a transaction
+	inTransaction() bool
+
+	// recordCopy inserts a copy of the record with the ID, with its contents,
+	// taking the ID, parent ID, name, extension and path of the copied record
+	recordCopy(id string, copied *Record) error
+
+	// recordCreate inserts the record, with autoSuffix adding a suffix
+	// to its name if it is taken instead of failing with ErrExists
+	recordCreate(record *Record, autoSuffix bool) error
+
+	// recordEnsureUnique checks that no other live record has the path, or
+	// the parent ID and name, of the record, see Store.recordEnsureUnique
+	recordEnsureUnique(record *Record, autoSuffix bool) error
+
+	// recordFindByID returns the record with the ID, or nil
+	recordFindByID(id string, options RecordQueryOptions) (*Record, error)
+
+	// recordFindByPath returns the live record with the path, or nil
+	recordFindByPath(path string, options RecordQueryOptions) (*Record, error)
+
+	// recordListAfter returns at most limit records matching the options,
+	// sorted by the clause then by ID, after the position if not nil
+	recordListAfter(options RecordQueryOptions, clause OrderClause, position *cursorPosition, limit int) ([]Record, error)
+
+	// recordReader returns a reader for the contents of the file record
+	recordReader(record *Record) (io.ReadSeekCloser, error)
+
+	// recordUpdate writes the changed fields of the record, a change of the
+	// contents being kept as a new version with the options, if any. The
+	// hash of changed contents is computed from them, unless given.
+	recordUpdate(record *Record, hash string, version *VersionOptions) error
+
+	// recordWriter returns a writer replacing the contents of the (clean)
+	// file path when closed, the file being created if it does not exist
+	recordWriter(filePath string) io.WriteCloser
+
+	// descendantsList returns the live descendants of the directory
+	// matching the options, in no particular order
+	descendantsList(directory *Record, options ListOptions) ([]Record, error)
+
+	// descendantsPathRewrite replaces the old path prefix of all the
+	// descendants of a directory (soft deleted included) with the new one
+	descendantsPathRewrite(oldPath string, newPath string) error
+
+	// descendantsRestore restores the descendants of the directory
+	// which were soft deleted at the same time as the directory
+	descendantsRestore(directory *Record, deletedAt string) error
+
+	// globList returns the live records which may match the (clean) pattern,
+	// at least all those matching it
+	globList(pattern string) ([]Record, error)
+
+	// chunksCopy copies the streamed contents of a record or version
+	// to another record
+	chunksCopy(fromRecordID string, toRecordID string) error
+
+	// versionContentsLoad loads the streamed contents of the version,
+	// which are not returned by versionFind
+	versionContentsLoad(version *Version) error
+
+	// versionCreate keeps the current contents of the record with the ID as
+	// its next version, and deletes the versions the retention does not keep
+	versionCreate(recordID string, options VersionOptions) error
+
+	// versionFind returns the version of the record with the ID with its
+	// number, with its contents unless they were streamed, or nil
+	versionFind(recordID string, number int) (*Version, error)
+
+	// versionList returns the versions of the record with the ID, the latest
+	// first, without their contents
+	versionList(recordID string) ([]Version, error)
+
+	// versionRecordIDs returns the IDs of the records which have versions
+	versionRecordIDs() ([]string, error)
+
+	// versionsPrune deletes the versions of the record with the ID which the
+	// retention does not keep, returning the number of versions deleted
+	versionsPrune(recordID string) (int, error)
+}
+
+// operations implements the operations shared by the stores,
+// on the primitives of the store
+type operations struct {
+	recordStorage
+}
+
+// transaction executes the function with the operations of a copy of the
+// store bound to a transaction, see Store.WithTx
+func (store operations) transaction(fn func(txStore operations) error) error {
+	return store.transactionRun(func(txStorage recordStorage) error {
+		return fn(operations{txStorage})
+	})
+}
@@ -0,0 +1,136 @@
+package sqlfilestore
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// PathRules define the rules the paths, and the names they are made of,
+// are validated against. A limit which is zero is not checked.
+type PathRules struct {
+	// MaxDepth is the maximum number of names in a path
+	MaxDepth int
+
+	// MaxPathLength is the maximum length of a path, in characters
+	MaxPathLength int
+
+	// MaxNameLength is the maximum length of a name, in characters
+	MaxNameLength int
+
+	// MaxExtensionLength is the maximum length of the extension of a file
+	MaxExtensionLength int
+
+	// ForbiddenCharacters are the characters a name must not contain,
+	// besides the path separator which is never allowed in a name
+	ForbiddenCharacters string
+
+	// ReservedNames are the names which are not allowed (e.g. "CON" and
+	// "NUL" on Windows), compared case-insensitively
+	ReservedNames []string
+}
+
+// DefaultPathRules returns the rules used when none are set. The limits
+// are the sizes of the path, name and extension columns, so that nothing
+// is truncated by the database.
+func DefaultPathRules() PathRules {
+	return PathRules{
+		MaxPathLength:       2048,
+		MaxNameLength:       100,
+		MaxExtensionLength:  12,
+		ForbiddenCharacters: "\x00",
+	}
+}
+
+// SetPathRules sets the rules the paths and names are validated against
+func (store *Store) SetPathRules(rules PathRules) {
+	store.pathRules = rules
+}
+
+// CleanPath returns the shortest absolute form of the path ("a//b/../c/"
+// becomes "/a/c"), checking it and each of its names against the rules
+func (rules PathRules) CleanPath(filePath string) (string, error) {
+	filePath = strings.TrimSpace(filePath)
+	filePath = path.Clean(PATH_SEPARATOR + strings.TrimLeft(filePath, PATH_SEPARATOR))
+
+	if filePath == ROOT_PATH {
+		return filePath, nil
+	}
+
+	if rules.MaxPathLength > 0 && utf8.RuneCountInString(filePath) > rules.MaxPathLength {
+		return filePath, ErrPathTooLong
+	}
+
+	names := strings.Split(strings.TrimPrefix(filePath, PATH_SEPARATOR), PATH_SEPARATOR)
+
+	if rules.MaxDepth > 0 && len(names) > rules.MaxDepth {
+		return filePath, ErrPathTooDeep
+	}
+
+	for _, name := range names {
+		err := rules.ValidateName(name)
+
+		if err != nil {
+			return filePath, err
+		}
+	}
+
+	return filePath, nil
+}
+
+// ValidateName checks the name of a file or directory against the rules.
+// The errors returned are of type *NameError.
+func (rules PathRules) ValidateName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return &NameError{Name: name, Err: ErrEmptyName}
+	}
+
+	if strings.Contains(name, PATH_SEPARATOR) || strings.ContainsAny(name, rules.ForbiddenCharacters) {
+		return &NameError{Name: name, Err: ErrForbiddenCharacter}
+	}
+
+	if rules.MaxNameLength > 0 && utf8.RuneCountInString(name) > rules.MaxNameLength {
+		return &NameError{Name: name, Err: ErrNameTooLong}
+	}
+
+	for _, reserved := range rules.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			return &NameError{Name: name, Err: ErrReservedName}
+		}
+	}
+
+	return nil
+}
+
+// ValidateExtension checks the extension of a file against the rules
+func (rules PathRules) ValidateExtension(extension string) error {
+	if rules.MaxExtensionLength > 0 && utf8.RuneCountInString(extension) > rules.MaxExtensionLength {
+		return &NameError{Name: extension, Err: ErrExtensionTooLong}
+	}
+
+	return nil
+}
+
+// validateData checks the name, path and extension in the record data,
+// those which are set, against the rules
+func (rules PathRules) validateData(data map[string]string) error {
+	if filePath, exists := data[COLUMN_PATH]; exists {
+		if _, err := rules.CleanPath(filePath); err != nil {
+			return err
+		}
+	}
+
+	if name, exists := data[COLUMN_NAME]; exists {
+		if err := rules.ValidateName(name); err != nil {
+			return err
+		}
+	}
+
+	if extension, exists := data[COLUMN_EXTENSION]; exists {
+		if err := rules.ValidateExtension(extension); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
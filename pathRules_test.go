@@ -0,0 +1,214 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestPathRulesCleanPath(t *testing.T) {
+	rules := DefaultPathRules()
+
+	for filePath, expected := range map[string]string{
+		"":               "/",
+		"/":              "/",
+		"a/b":            "/a/b",
+		" /a/b/ ":        "/a/b",
+		"//a//b":         "/a/b",
+		"/a//b/../c/":    "/a/c",
+		"/a/./b":         "/a/b",
+		"/../a":          "/a",
+		"/report (1).md": "/report (1).md",
+	} {
+		cleanPath, err := rules.CleanPath(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if cleanPath != expected {
+			t.Fatal("expected:", expected, "found:", cleanPath)
+		}
+	}
+
+	for filePath, expected := range map[string]error{
+		"/a/b\x00c":                         ErrForbiddenCharacter,
+		"/" + strings.Repeat("n", 101):      ErrNameTooLong,
+		"/" + strings.Repeat("d/", 1025):    ErrPathTooLong,
+		"/docs/" + strings.Repeat("ä", 101): ErrNameTooLong,
+	} {
+		_, err := rules.CleanPath(filePath)
+
+		if !errors.Is(err, expected) {
+			t.Fatal("expected:", expected, "found:", err)
+		}
+
+		if !errors.Is(err, ErrInvalidPath) || !errors.Is(err, fs.ErrInvalid) {
+			t.Fatal("The error MUST match ErrInvalidPath and fs.ErrInvalid:", err)
+		}
+	}
+}
+
+func TestPathRulesValidateName(t *testing.T) {
+	rules := PathRules{
+		MaxNameLength:       10,
+		ForbiddenCharacters: "\x00:*?",
+		ReservedNames:       []string{"CON", "NUL"},
+	}
+
+	for _, name := range []string{"report.md", "a b", "ä"} {
+		err := rules.ValidateName(name)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for name, expected := range map[string]error{
+		"":            ErrEmptyName,
+		".":           ErrEmptyName,
+		"..":          ErrEmptyName,
+		"a/b":         ErrForbiddenCharacter,
+		"a:b":         ErrForbiddenCharacter,
+		"con":         ErrReservedName,
+		"report.html": ErrNameTooLong,
+	} {
+		err := rules.ValidateName(name)
+
+		if !errors.Is(err, expected) {
+			t.Fatal("expected:", expected, "found:", err)
+		}
+
+		var nameErr *NameError
+
+		if !errors.As(err, &nameErr) || nameErr.Name != name {
+			t.Fatal("The error MUST be a *NameError for the name:", name, err)
+		}
+	}
+
+	_, err := PathRules{MaxDepth: 2}.CleanPath("/a/b/c")
+
+	if !errors.Is(err, ErrPathTooDeep) {
+		t.Fatal("expected ErrPathTooDeep, found:", err)
+	}
+}
+
+func TestStorePathRules(t *testing.T) {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          "file_path_rules",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	testPathRules(t, store)
+}
+
+func TestMemoryStorePathRules(t *testing.T) {
+	testPathRules(t, NewMemoryStore())
+}
+
+func testPathRules(t *testing.T, store StoreInterface) {
+	err := store.MkdirAll("/docs//2026/")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/2026/../readme.md", []byte("README"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("docs//readme.md", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil || record.Path() != "/docs/readme.md" {
+		t.Fatal("The record MUST be at the clean path:", record)
+	}
+
+	for filePath, expected := range map[string]error{
+		"/docs/read\x00me.md":                            ErrForbiddenCharacter,
+		"/docs/" + strings.Repeat("n", 101):              ErrNameTooLong,
+		"/docs/report." + strings.Repeat("e", 13):        ErrExtensionTooLong,
+		"/docs/2026/" + strings.Repeat("d/", 1020) + "a": ErrPathTooLong,
+	} {
+		err = store.WriteFile(filePath, []byte("DATA"))
+
+		if !errors.Is(err, expected) {
+			t.Fatal("expected:", expected, "found:", err)
+		}
+
+		var pathErr *fs.PathError
+
+		if !errors.As(err, &pathErr) {
+			t.Fatal("The error MUST be a *fs.PathError:", err)
+		}
+	}
+
+	err = store.Move(record.ID(), ROOT_ID, "a/b")
+
+	if !errors.Is(err, ErrForbiddenCharacter) {
+		t.Fatal("expected ErrForbiddenCharacter, found:", err)
+	}
+
+	err = store.RecordCreate(NewFile().
+		SetParentID(ROOT_ID).
+		SetName(strings.Repeat("n", 101)).
+		SetPath("/" + strings.Repeat("n", 101)).
+		SetContents("").
+		SetSize("0"))
+
+	if !errors.Is(err, ErrNameTooLong) {
+		t.Fatal("expected ErrNameTooLong, found:", err)
+	}
+
+	record.SetExtension(strings.Repeat("e", 13))
+
+	err = store.RecordUpdate(record)
+
+	if !errors.Is(err, ErrExtensionTooLong) {
+		t.Fatal("expected ErrExtensionTooLong, found:", err)
+	}
+
+	store.SetPathRules(PathRules{
+		MaxDepth:            2,
+		ForbiddenCharacters: ":",
+		ReservedNames:       []string{"CON"},
+	})
+
+	err = store.MkdirAll("/docs/2026/10")
+
+	if !errors.Is(err, ErrPathTooDeep) {
+		t.Fatal("expected ErrPathTooDeep, found:", err)
+	}
+
+	err = store.Mkdir("/con")
+
+	if !errors.Is(err, ErrReservedName) {
+		t.Fatal("expected ErrReservedName, found:", err)
+	}
+
+	err = store.WriteFile("/docs/a:b.md", []byte("DATA"))
+
+	if !errors.Is(err, ErrForbiddenCharacter) {
+		t.Fatal("expected ErrForbiddenCharacter, found:", err)
+	}
+
+	// The limits which are not set are not checked
+	err = store.WriteFile("/docs/"+strings.Repeat("n", 101), []byte("DATA"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
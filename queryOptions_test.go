@@ -0,0 +1,159 @@
+package sqlfilestore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gouniverse/sb"
+)
+
+func TestStoreRecordQueryOptions(t *testing.T) {
+	testRecordQueryOptions(t, initTxStore(t, "file_query_options"))
+}
+
+func TestMemoryStoreRecordQueryOptions(t *testing.T) {
+	testRecordQueryOptions(t, NewMemoryStore())
+}
+
+func TestLikeMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"report%", "report.pdf", true},
+		{"report%", "a report", false},
+		{"%.md", "notes.md", true},
+		{"_.md", "a.md", true},
+		{"_.md", "ab.md", false},
+		{"100!%", "100%", true},
+		{"100!%", "1000", false},
+		{"a.b", "aXb", false},
+	} {
+		if likeMatch(test.pattern, test.value) != test.expected {
+			t.Fatal("expected", test.pattern, "to match", test.value, ":", test.expected)
+		}
+	}
+}
+
+func testRecordQueryOptions(t *testing.T, store StoreInterface) {
+	for _, dirPath := range []string{"/docs", "/other"} {
+		err := store.Mkdir(dirPath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for filePath, contents := range map[string]string{
+		"/docs/report.pdf":  "0123456789",
+		"/docs/report.md":   "01234",
+		"/docs/notes.md":    "0123456789012345",
+		"/other/report.txt": "",
+		"/other/deleted.md": "012",
+	} {
+		err := store.WriteFile(filePath, []byte(contents))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	deleted, err := store.RecordFindByPath("/other/deleted.md", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(deleted)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	deleted, err = store.RecordFindByID(deleted.ID(), RecordQueryOptions{WithSoftDeleted: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	other, err := store.RecordFindByPath("/other", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	files := func(options RecordQueryOptions) string {
+		options.Type = TYPE_FILE
+
+		if len(options.OrderBy) == 0 {
+			options.OrderBy = []OrderClause{{Column: COLUMN_PATH, SortOrder: sb.ASC}}
+		}
+
+		records, err := store.RecordList(options)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		count, err := store.RecordCount(options)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if count != int64(len(records)) {
+			t.Fatal("RecordCount MUST honor the options, expected:", len(records), "found:", count)
+		}
+
+		paths := []string{}
+
+		for _, record := range records {
+			paths = append(paths, record.Path())
+		}
+
+		return strings.Join(paths, " ")
+	}
+
+	for _, test := range []struct {
+		options  RecordQueryOptions
+		expected string
+	}{
+		{RecordQueryOptions{NameLike: "report%"}, "/docs/report.md /docs/report.pdf /other/report.txt"},
+		{RecordQueryOptions{NameLike: "%.md"}, "/docs/notes.md /docs/report.md"},
+		{RecordQueryOptions{NameIn: []string{"notes.md", "report.txt"}}, "/docs/notes.md /other/report.txt"},
+		{RecordQueryOptions{Extension: "md"}, "/docs/notes.md /docs/report.md"},
+		{RecordQueryOptions{ExtensionIn: []string{"pdf", "txt"}}, "/docs/report.pdf /other/report.txt"},
+		{RecordQueryOptions{SizeGreaterThan: "5"}, "/docs/notes.md /docs/report.pdf"},
+		{RecordQueryOptions{SizeLessThan: "10"}, "/docs/report.md /other/report.txt"},
+		{RecordQueryOptions{SizeGreaterThan: "0", SizeLessThan: "16"}, "/docs/report.md /docs/report.pdf"},
+		{RecordQueryOptions{ParentIDIn: []string{other.ID()}}, "/other/report.txt"},
+		{RecordQueryOptions{ParentIDIn: []string{docs.ID(), other.ID()}, Extension: "md"}, "/docs/notes.md /docs/report.md"},
+		{RecordQueryOptions{PathIn: []string{"/docs/report.md", "/other/deleted.md"}}, "/docs/report.md"},
+		{RecordQueryOptions{OnlySoftDeleted: true}, "/other/deleted.md"},
+		{RecordQueryOptions{WithSoftDeleted: true, Extension: "md"}, "/docs/notes.md /docs/report.md /other/deleted.md"},
+		{RecordQueryOptions{OnlySoftDeleted: true, PathIn: []string{"/other/deleted.md"}}, "/other/deleted.md"},
+		{RecordQueryOptions{OnlySoftDeleted: true, Path: "/other/deleted.md"}, "/other/deleted.md"},
+		{RecordQueryOptions{OnlySoftDeleted: true, DeletedAtGreaterThan: deleted.DeletedAt()}, ""},
+		{RecordQueryOptions{OrderBy: []OrderClause{
+			{Column: COLUMN_EXTENSION, SortOrder: sb.ASC},
+			{Column: COLUMN_SIZE, SortOrder: sb.DESC},
+		}}, "/docs/notes.md /docs/report.md /docs/report.pdf /other/report.txt"},
+		{RecordQueryOptions{OrderBy: []OrderClause{
+			{Column: COLUMN_NAME},
+			{Column: COLUMN_PATH},
+		}}, "/other/report.txt /docs/report.pdf /docs/report.md /docs/notes.md"},
+	} {
+		paths := files(test.options)
+
+		if paths != test.expected {
+			t.Fatal("expected:", test.expected, "found:", paths)
+		}
+	}
+}
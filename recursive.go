@@ -0,0 +1,171 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"log"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+// RecordSoftDeleteRecursive soft deletes the record and, for a directory,
+// everything it contains. The whole subtree is stamped with the same
+// deleted_at, so that it is restored together by RecordRestore.
+// Records which were already soft deleted keep their own deleted_at.
+//
+// Use RecordSoftDelete to soft delete the record alone.
+func (store *Store) RecordSoftDeleteRecursive(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	if record.ID() == ROOT_ID {
+		return errors.New("root directory cannot be deleted")
+	}
+
+	return store.transaction(func(txStore *Store) error {
+		subtree, err := txStore.subtreeWhere(record.ID())
+
+		if err != nil {
+			return err
+		}
+
+		now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+		sqlStr, params, errSql := goqu.Dialect(txStore.dbDriverName).
+			Update(txStore.tableName).
+			Prepared(true).
+			Set(goqu.Record{
+				COLUMN_DELETED_AT: now,
+				COLUMN_UPDATED_AT: now,
+			}).
+			Where(subtree, goqu.C(COLUMN_DELETED_AT).Eq(sb.NULL_DATETIME)).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = txStore.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+
+		record.SetDeletedAt(now)
+		record.SetUpdatedAt(now)
+		record.MarkAsNotDirty()
+
+		return nil
+	})
+}
+
+// RecordDeleteRecursive hard deletes the record and, for a directory,
+// everything it contains, soft deleted records included. The subtree
+// and its streamed contents are removed in a single transaction.
+//
+// Use RecordDelete, which refuses to delete a directory that is not
+// empty, to delete the record alone.
+func (store *Store) RecordDeleteRecursive(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	if record.ID() == ROOT_ID {
+		return errors.New("root directory cannot be deleted")
+	}
+
+	return store.transaction(func(txStore *Store) error {
+		subtree, err := txStore.subtreeWhere(record.ID())
+
+		if err != nil {
+			return err
+		}
+
+		err = txStore.versionsDelete(subtree)
+
+		if err != nil {
+			return err
+		}
+
+		err = txStore.contentsRelease(txStore.tableName, subtree)
+
+		if err != nil {
+			return err
+		}
+
+		subtreeIDs := goqu.Dialect(txStore.dbDriverName).
+			From(txStore.tableName).
+			Select(goqu.C(COLUMN_ID)).
+			Where(subtree)
+
+		sqlStr, params, errSql := goqu.Dialect(txStore.dbDriverName).
+			Delete(txStore.chunkTableName).
+			Prepared(true).
+			Where(goqu.C(COLUMN_RECORD_ID).In(subtreeIDs)).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = txStore.executeSql(sqlStr, params...)
+
+		if err != nil {
+			return err
+		}
+
+		sqlStr, params, errSql = goqu.Dialect(txStore.dbDriverName).
+			Delete(txStore.tableName).
+			Prepared(true).
+			Where(subtree).
+			ToSQL()
+
+		if errSql != nil {
+			return errSql
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		_, err = txStore.executeSql(sqlStr, params...)
+
+		return err
+	})
+}
+
+// == PRIVATE METHODS ========================================================
+
+// subtreeWhere returns the condition matching the record with the ID
+// and, for a directory, all its descendants (soft deleted included)
+func (store *Store) subtreeWhere(id string) (exp.Expression, error) {
+	record, err := store.recordFindByID(id, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_TYPE, COLUMN_PATH},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if record == nil {
+		return nil, ErrNotFound
+	}
+
+	if !record.IsDirectory() {
+		return goqu.C(COLUMN_ID).Eq(record.ID()), nil
+	}
+
+	return goqu.Or(goqu.C(COLUMN_ID).Eq(record.ID()), subtreeWhere(record.Path())), nil
+}
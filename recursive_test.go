@@ -0,0 +1,226 @@
+package sqlfilestore
+
+import (
+	"testing"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+func initRecursiveStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+		ChunkSize:          4,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, dirPath := range []string{"/docs/2026", "/docs_old"} {
+		err = store.MkdirAll(dirPath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, filePath := range []string{"/docs/readme.txt", "/docs/2026/report.txt", "/docs_old/notes.txt"} {
+		err = store.WriteFile(filePath, []byte(filePath))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	writer, err := store.CreateWriter("/docs/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("STREAMED CONTENTS"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func TestStoreRecordSoftDeleteRecursive(t *testing.T) {
+	store := initRecursiveStore(t, "file_soft_delete_recursive")
+
+	report, err := store.RecordFindByPath("/docs/2026/report.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Trashed earlier, must keep its own deleted_at
+	earlier := carbon.Now(carbon.UTC).SubDays(1).ToDateTimeString(carbon.UTC)
+	report.SetDeletedAt(earlier)
+
+	err = store.RecordUpdate(report)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDeleteRecursive(docs)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs/"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST remain visible under /docs, found:", count)
+	}
+
+	trashed, err := store.TrashList(RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(trashed) != 5 {
+		t.Fatal("Trash MUST contain 5 records, found:", len(trashed))
+	}
+
+	for _, record := range trashed {
+		if record.ID() == report.ID() {
+			if record.DeletedAt() != earlier {
+				t.Fatal("Earlier deleted_at MUST be kept, found:", record.DeletedAt())
+			}
+		} else if record.DeletedAt() != docs.DeletedAt() {
+			t.Fatal("Subtree MUST be stamped with the same deleted_at:", record.Data())
+		}
+	}
+
+	_, err = store.Stat("/docs_old/notes.txt")
+
+	if err != nil {
+		t.Fatal("unrelated file MUST NOT be deleted:", err)
+	}
+
+	// Restoring the directory restores what was deleted with it
+	err = store.RecordRestore(docs)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/docs/2026/streamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "STREAMED CONTENTS" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+
+	record, err := store.RecordFindByID(report.ID(), RecordQueryOptions{WithSoftDeleted: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.DeletedAt() == sb.NULL_DATETIME {
+		t.Fatal("Record trashed earlier MUST remain soft deleted")
+	}
+}
+
+func TestStoreRecordDeleteRecursive(t *testing.T) {
+	store := initRecursiveStore(t, "file_delete_recursive")
+
+	docs, err := store.RecordFindByPath("/docs", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDelete(docs)
+
+	if err == nil {
+		t.Fatal("must return error as the directory is not empty")
+	}
+
+	streamed, err := store.RecordFindByPath("/docs/2026/streamed.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(streamed)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDeleteRecursive(docs)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs/", WithSoftDeleted: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST remain under /docs, found:", count)
+	}
+
+	chunks, err := store.chunkList(streamed.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 0 {
+		t.Fatal("Chunks MUST be deleted, found:", len(chunks))
+	}
+
+	_, err = store.Stat("/docs_old/notes.txt")
+
+	if err != nil {
+		t.Fatal("unrelated file MUST NOT be deleted:", err)
+	}
+
+	root, err := store.RecordFindByID(ROOT_ID, RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDeleteRecursive(root)
+
+	if err == nil {
+		t.Fatal("must return error as the root cannot be deleted")
+	}
+}
@@ -0,0 +1,25 @@
+package sqlfilestore
+
+import (
+	"errors"
+
+	"github.com/gouniverse/sb"
+)
+
+// sqlContentsToBinary returns the SQL converting the contents column
+// of the table (record or content) from text to binary, keeping the
+// stored bytes
+func (st *Store) sqlContentsToBinary(tableName string) (string, error) {
+	switch st.dbDriverName {
+	case sb.DIALECT_MYSQL:
+		return "ALTER TABLE `" + tableName + "` MODIFY `" + COLUMN_CONTENTS + "` LONGBLOB NOT NULL;", nil
+	case sb.DIALECT_POSTGRES:
+		return `ALTER TABLE "` + tableName + `" ALTER COLUMN "` + COLUMN_CONTENTS + `" TYPE BYTEA USING convert_to("` + COLUMN_CONTENTS + `", 'UTF8');`, nil
+	case sb.DIALECT_SQLITE:
+		// SQLite columns are dynamically typed, it is the stored values
+		// which are converted, the declared type of the column is kept
+		return `UPDATE "` + tableName + `" SET "` + COLUMN_CONTENTS + `" = CAST("` + COLUMN_CONTENTS + `" AS BLOB);`, nil
+	}
+
+	return "", errors.New("converting the contents to binary is not supported for driver " + st.dbDriverName)
+}
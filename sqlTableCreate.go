@@ -2,8 +2,15 @@ package sqlfilestore
 
 import "github.com/gouniverse/sb"
 
-
+// sqlTableCreate returns the SQL creating the record table, as created by
+// the first migration. It is not to be changed: new columns are added by
+// their own migrations, with columnAdd.
 func (st *Store) sqlTableCreate() string {
+	contentsType := sb.COLUMN_TYPE_LONGTEXT
+	if st.binaryContents {
+		contentsType = sb.COLUMN_TYPE_BLOB
+	}
+
 	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
 		Table(st.tableName).
 		Column(sb.Column{
@@ -29,7 +36,7 @@ func (st *Store) sqlTableCreate() string {
 		}).
 		Column(sb.Column{
 			Name: COLUMN_CONTENTS,
-			Type: sb.COLUMN_TYPE_LONGTEXT,
+			Type: contentsType,
 		}).
 		Column(sb.Column{
 			Name: COLUMN_SIZE,
@@ -62,3 +69,207 @@ func (st *Store) sqlTableCreate() string {
 
 	return sql
 }
+
+func (st *Store) sqlChunkTableCreate() string {
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.chunkTableName).
+		Column(sb.Column{
+			Name:       COLUMN_ID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			Length:     40,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_RECORD_ID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_SEQUENCE,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_SIZE,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_DATA,
+			Type: sb.COLUMN_TYPE_BLOB,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CREATED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlContentTableCreate returns the SQL creating the table the contents
+// are kept in when the content table is enabled
+func (st *Store) sqlContentTableCreate() string {
+	contentsType := sb.COLUMN_TYPE_LONGTEXT
+	if st.binaryContents {
+		contentsType = sb.COLUMN_TYPE_BLOB
+	}
+
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.contentTableName).
+		Column(sb.Column{
+			Name:       COLUMN_ID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			Length:     40,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CONTENTS,
+			Type: contentsType,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CREATED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlVersionTableCreate returns the SQL creating the table the versions
+// of the files are kept in
+func (st *Store) sqlVersionTableCreate() string {
+	contentsType := sb.COLUMN_TYPE_LONGTEXT
+	if st.binaryContents {
+		contentsType = sb.COLUMN_TYPE_BLOB
+	}
+
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.versionTableName).
+		Column(sb.Column{
+			Name:       COLUMN_ID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			Length:     40,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_RECORD_ID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_VERSION,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_SIZE,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_HASH,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 64,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CONTENTS,
+			Type: contentsType,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_CONTENT_ID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_AUTHOR,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 255,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_COMMENT,
+			Type: sb.COLUMN_TYPE_TEXT,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CREATED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlUniqueIndexCreate returns the SQL creating the partial unique index
+// on the parent ID and name of the live records, or an empty string for
+// the databases not supporting partial indexes (MySQL)
+func (st *Store) sqlUniqueIndexCreate() string {
+	switch st.dbDriverName {
+	case sb.DIALECT_POSTGRES, sb.DIALECT_SQLITE:
+		return `CREATE UNIQUE INDEX IF NOT EXISTS "` + st.tableName + `_parent_id_name_unique" ON "` + st.tableName + `" ("` + COLUMN_PARENT_ID + `", "` + COLUMN_NAME + `") WHERE "` + COLUMN_DELETED_AT + `" = '` + sb.NULL_DATETIME + `';`
+	}
+
+	return ""
+}
+
+// sqlContentHashIndexCreate returns the SQL creating the unique index on
+// the hash of the content rows, so that identical contents are kept once
+func (st *Store) sqlContentHashIndexCreate() string {
+	indexName := st.contentHashIndexName()
+
+	if st.dbDriverName == sb.DIALECT_MYSQL {
+		return "CREATE UNIQUE INDEX `" + indexName + "` ON `" + st.contentTableName + "` (`" + COLUMN_HASH + "`);"
+	}
+
+	return `CREATE UNIQUE INDEX IF NOT EXISTS "` + indexName + `" ON "` + st.contentTableName + `" ("` + COLUMN_HASH + `");`
+}
+
+// contentHashIndexName returns the name of the unique index on the hash
+// of the content rows
+func (st *Store) contentHashIndexName() string {
+	return st.contentTableName + "_" + COLUMN_HASH + "_unique"
+}
+
+// sqlSchemaTableCreate returns the SQL creating the table
+// keeping the versions of the applied migrations
+func (st *Store) sqlSchemaTableCreate() string {
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.schemaTableName()).
+		Column(sb.Column{
+			Name:       COLUMN_VERSION,
+			Type:       sb.COLUMN_TYPE_INTEGER,
+			PrimaryKey: true,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_NAME,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 255,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_APPLIED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlIndexCreate returns the SQL creating the index on the column of the
+// table. On MySQL the path is indexed by its first 255 characters, as
+// the index key length is limited. MySQL having no IF NOT EXISTS for the
+// indexes, the SQL is to be run with indexCreate.
+func (st *Store) sqlIndexCreate(tableName string, columnName string) string {
+	indexName := columnIndexName(tableName, columnName)
+
+	if st.dbDriverName == sb.DIALECT_MYSQL {
+		column := "`" + columnName + "`"
+
+		if columnName == COLUMN_PATH {
+			column += "(255)"
+		}
+
+		return "CREATE INDEX `" + indexName + "` ON `" + tableName + "` (" + column + ");"
+	}
+
+	return `CREATE INDEX IF NOT EXISTS "` + indexName + `" ON "` + tableName + `" ("` + columnName + `");`
+}
+
+// columnIndexName returns the name of the index on the column of the table
+func columnIndexName(tableName string, columnName string) string {
+	return tableName + "_" + columnName + "_idx"
+}
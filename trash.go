@@ -0,0 +1,269 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+// RecordRestore restores a soft deleted record, making it visible again.
+//
+// Soft deleted ancestors of the record are restored as well, so that
+// the record is reachable by its path. For a directory, the descendants
+// soft deleted together with it by RecordSoftDeleteRecursive are
+// restored. Restoring fails if an ancestor has been hard deleted, or if
+// another record now exists at the path of the record or of one of its
+// restored ancestors.
+func (store *Store) RecordRestore(record *Record) error {
+	return operations{store}.recordRestore(record)
+}
+
+// RecordRestore restores a soft deleted record and its soft deleted
+// ancestors, see Store.RecordRestore
+func (store *MemoryStore) RecordRestore(record *Record) error {
+	return operations{store}.recordRestore(record)
+}
+
+// RecordRestoreByID restores the soft deleted record with the ID,
+// see RecordRestore
+func (store *Store) RecordRestoreByID(id string) error {
+	return operations{store}.recordRestoreByID(id)
+}
+
+// RecordRestoreByID restores the soft deleted record with the ID,
+// see Store.RecordRestore
+func (store *MemoryStore) RecordRestoreByID(id string) error {
+	return operations{store}.recordRestoreByID(id)
+}
+
+// TrashList returns the soft deleted records matching the options
+func (store *Store) TrashList(options RecordQueryOptions) ([]Record, error) {
+	return operations{store}.trashList(options)
+}
+
+// TrashList returns the soft deleted records matching the options
+func (store *MemoryStore) TrashList(options RecordQueryOptions) ([]Record, error) {
+	return operations{store}.trashList(options)
+}
+
+// TrashPurge hard deletes the records soft deleted more than olderThan ago,
+// returning the number of records deleted.
+//
+// Everything contained in a purged directory is deleted with it, the records
+// being deleted bottom-up in a single transaction.
+func (store *Store) TrashPurge(olderThan time.Duration) (int, error) {
+	return operations{store}.trashPurge(olderThan)
+}
+
+// TrashPurge hard deletes the records soft deleted more than olderThan ago,
+// with everything they contain, returning the number of records deleted
+func (store *MemoryStore) TrashPurge(olderThan time.Duration) (int, error) {
+	return operations{store}.trashPurge(olderThan)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// recordRestore implements RecordRestore
+func (store operations) recordRestore(record *Record) error {
+	if record == nil {
+		return errors.New("record is nil")
+	}
+
+	return store.RecordRestoreByID(record.ID())
+}
+
+// recordRestoreByID implements RecordRestoreByID
+func (store operations) recordRestoreByID(id string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+
+	return store.transaction(func(txStore operations) error {
+		record, err := txStore.recordFindByID(id, RecordQueryOptions{
+			Columns:         fsMetadataColumns,
+			WithSoftDeleted: true,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if record == nil {
+			return ErrNotFound
+		}
+
+		deletedAt := record.DeletedAt()
+
+		// The record goes first, followed by the ancestors up to the root
+		restored := []*Record{}
+
+		for current := record; current.ID() != ROOT_ID; {
+			if current.DeletedAt() != sb.NULL_DATETIME {
+				restored = append(restored, current)
+			}
+
+			parent, err := txStore.recordFindByID(current.ParentID(), RecordQueryOptions{
+				Columns:         fsMetadataColumns,
+				WithSoftDeleted: true,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if parent == nil {
+				return wrapError(ErrNotFound, "parent record not found: "+current.Path())
+			}
+
+			current = parent
+		}
+
+		for _, record := range restored {
+			existing, err := txStore.recordFindByPath(record.Path(), RecordQueryOptions{Columns: []string{COLUMN_ID}})
+
+			if err != nil {
+				return err
+			}
+
+			if existing != nil {
+				return wrapError(ErrExists, "record already exists at path: "+record.Path())
+			}
+
+			record.SetDeletedAt(sb.NULL_DATETIME)
+
+			err = txStore.RecordUpdate(record)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if !record.IsDirectory() || deletedAt == sb.NULL_DATETIME {
+			return nil
+		}
+
+		return txStore.descendantsRestore(record, deletedAt)
+	})
+}
+
+// trashList implements TrashList
+func (store operations) trashList(options RecordQueryOptions) ([]Record, error) {
+	options.OnlySoftDeleted = true
+
+	return store.RecordList(options)
+}
+
+// trashPurge implements TrashPurge
+func (store operations) trashPurge(olderThan time.Duration) (int, error) {
+	deletedBefore := carbon.CreateFromStdTime(time.Now().Add(-olderThan)).ToDateTimeString(carbon.UTC)
+
+	purged := 0
+
+	err := store.transaction(func(txStore operations) error {
+		expired, err := txStore.TrashList(RecordQueryOptions{
+			DeletedAtLessThan: deletedBefore,
+			Columns:           []string{COLUMN_ID, COLUMN_TYPE, COLUMN_PATH},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		records := map[string]Record{}
+
+		for _, record := range expired {
+			if record.ID() == ROOT_ID {
+				continue
+			}
+
+			records[record.ID()] = record
+
+			if !record.IsDirectory() {
+				continue
+			}
+
+			descendants, err := txStore.RecordList(RecordQueryOptions{
+				PathStartsWith:  record.Path() + PATH_SEPARATOR,
+				Columns:         []string{COLUMN_ID, COLUMN_TYPE, COLUMN_PATH},
+				WithSoftDeleted: true,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			for _, descendant := range descendants {
+				if strings.HasPrefix(descendant.Path(), record.Path()+PATH_SEPARATOR) {
+					records[descendant.ID()] = descendant
+				}
+			}
+		}
+
+		ordered := make([]Record, 0, len(records))
+
+		for _, record := range records {
+			ordered = append(ordered, record)
+		}
+
+		// The deepest records go first, so that directories are empty when reached
+		sort.Slice(ordered, func(i, j int) bool {
+			return strings.Count(ordered[i].Path(), PATH_SEPARATOR) > strings.Count(ordered[j].Path(), PATH_SEPARATOR)
+		})
+
+		for _, record := range ordered {
+			err = txStore.RecordDeleteByID(record.ID())
+
+			if err != nil {
+				return err
+			}
+		}
+
+		purged = len(ordered)
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// descendantsRestore restores the descendants of the directory
+// which were soft deleted at the same time as the directory
+func (store *Store) descendantsRestore(directory *Record, deletedAt string) error {
+	subtree, err := store.subtreeWhere(directory.ID())
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Update(store.tableName).
+		Prepared(true).
+		Set(goqu.Record{
+			COLUMN_DELETED_AT: sb.NULL_DATETIME,
+			COLUMN_UPDATED_AT: carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+		}).
+		Where(subtree, goqu.C(COLUMN_DELETED_AT).Eq(deletedAt)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return err
+}
@@ -0,0 +1,250 @@
+package sqlfilestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+)
+
+func initTrashStore(t *testing.T, tableName string) *Store {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          tableName,
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.MkdirAll("/docs/2026")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, filePath := range []string{"/docs/readme.txt", "/docs/2026/report.txt", "/notes.txt"} {
+		err = store.WriteFile(filePath, []byte(filePath))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	return store
+}
+
+func softDeleteAt(t *testing.T, store *Store, recordPath string, deletedAt string) *Record {
+	record, err := store.RecordFindByPath(recordPath, RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record == nil {
+		t.Fatal("Record MUST NOT be nil:", recordPath)
+	}
+
+	record.SetDeletedAt(deletedAt)
+
+	err = store.RecordUpdate(record)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return record
+}
+
+func TestStoreRecordRestore(t *testing.T) {
+	store := initTrashStore(t, "file_restore")
+
+	notes, err := store.RecordFindByPath("/notes.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(notes)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/notes.txt")
+
+	if err == nil {
+		t.Fatal("must return error as the file is soft deleted")
+	}
+
+	err = store.RecordRestore(notes)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = store.Stat("/notes.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordRestoreByID("missing")
+
+	if err == nil {
+		t.Fatal("must return error as the record does not exist")
+	}
+}
+
+func TestStoreRecordRestoreAncestors(t *testing.T) {
+	store := initTrashStore(t, "file_restore_ancestors")
+
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+	softDeleteAt(t, store, "/docs", now)
+	softDeleteAt(t, store, "/docs/2026", now)
+	report := softDeleteAt(t, store, "/docs/2026/report.txt", now)
+
+	err := store.RecordRestoreByID(report.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/docs/2026/report.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "/docs/2026/report.txt" {
+		t.Fatal("unexpected contents:", string(contents))
+	}
+}
+
+func TestStoreRecordRestoreFailsWhenPathIsTaken(t *testing.T) {
+	store := initTrashStore(t, "file_restore_taken")
+
+	notes := softDeleteAt(t, store, "/notes.txt", carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	err := store.WriteFile("/notes.txt", []byte("NEW NOTES"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordRestore(notes)
+
+	if err == nil {
+		t.Fatal("must return error as another record exists at the path")
+	}
+}
+
+func TestStoreRecordRestoreFailsWhenParentIsDeleted(t *testing.T) {
+	store := initTrashStore(t, "file_restore_parent_deleted")
+
+	report := softDeleteAt(t, store, "/docs/2026/report.txt", carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	year, err := store.RecordFindByPath("/docs/2026", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Detach the report, so that its parent can be hard deleted
+	report.SetParentID("missing")
+
+	err = store.RecordUpdate(report)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordDelete(year)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordRestore(report)
+
+	if err == nil {
+		t.Fatal("must return error as the parent record does not exist")
+	}
+
+	record, err := store.RecordFindByID(report.ID(), RecordQueryOptions{WithSoftDeleted: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if record.DeletedAt() == sb.NULL_DATETIME {
+		t.Fatal("Record MUST remain soft deleted")
+	}
+}
+
+func TestStoreTrashList(t *testing.T) {
+	store := initTrashStore(t, "file_trash_list")
+
+	softDeleteAt(t, store, "/notes.txt", carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	list, err := store.TrashList(RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 {
+		t.Fatal("Trash MUST contain 1 record, found:", len(list))
+	}
+
+	if list[0].Path() != "/notes.txt" {
+		t.Fatal("unexpected record:", list[0].Data())
+	}
+}
+
+func TestStoreTrashPurge(t *testing.T) {
+	store := initTrashStore(t, "file_trash_purge")
+
+	longAgo := carbon.Now(carbon.UTC).SubDays(40).ToDateTimeString(carbon.UTC)
+	recently := carbon.Now(carbon.UTC).SubDays(1).ToDateTimeString(carbon.UTC)
+
+	softDeleteAt(t, store, "/docs/2026/report.txt", recently)
+	softDeleteAt(t, store, "/docs", longAgo)
+	softDeleteAt(t, store, "/notes.txt", recently)
+
+	purged, err := store.TrashPurge(30 * 24 * time.Hour)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The directory, its subdirectory and its two files
+	if purged != 4 {
+		t.Fatal("Purged MUST be 4, found:", purged)
+	}
+
+	count, err := store.RecordCount(RecordQueryOptions{PathStartsWith: "/docs", WithSoftDeleted: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("No record MUST remain under /docs, found:", count)
+	}
+
+	list, err := store.TrashList(RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 || list[0].Path() != "/notes.txt" {
+		t.Fatal("Only the recently deleted file MUST remain in the trash, found:", len(list))
+	}
+}
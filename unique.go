@@ -0,0 +1,251 @@
+package sqlfilestore
+
+import (
+	"log"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/gouniverse/sb"
+)
+
+// There is at most one live (not soft deleted) record per path, and per
+// parent ID and name. The store checks it in the transaction inserting or
+// updating the record, having locked the parent record first (MySQL and
+// PostgreSQL), so that concurrent inserts in the same directory are
+// serialized. On SQLite and PostgreSQL a partial unique index on the
+// parent ID and name backs the check, on MySQL (where partial indexes
+// are not supported) the check relies on the lock alone.
+
+// EnableAutoSuffix enables or disables the auto suffix option. When enabled,
+// RecordCreate and Copy do not fail with ErrExists when the name is taken,
+// they add the first free suffix to it instead ("report.pdf" is created as
+// "report (1).pdf", then "report (2).pdf"). Move and Rename are not
+// affected, as they are given the new name explicitly.
+func (store *Store) EnableAutoSuffix(enabled bool) {
+	store.autoSuffixEnabled = enabled
+}
+
+// suffixRegexp matches a name ending with a suffix, e.g. "report (1)"
+var suffixRegexp = regexp.MustCompile(`^(.+) \(\d+\)$`)
+
+// suffixAttemptsMax is the number of suffixes tried before giving up
+// on finding a free name, each attempt costing a query
+const suffixAttemptsMax = 100
+
+// == PRIVATE METHODS ========================================================
+
+// recordConflict returns the live record, other than the record with the ID,
+// which has the path or the parent ID and name, or nil if there is none
+func (store *Store) recordConflict(id string, parentID string, name string, recordPath string) (*Record, error) {
+	query := goqu.Dialect(store.dbDriverName).
+		From(store.tableName).
+		Prepared(true).
+		Select(COLUMN_ID, COLUMN_PATH).
+		Where(
+			goqu.C(COLUMN_ID).Neq(id),
+			goqu.C(COLUMN_DELETED_AT).Eq(sb.NULL_DATETIME),
+			goqu.Or(
+				goqu.C(COLUMN_PATH).Eq(recordPath),
+				goqu.And(
+					goqu.C(COLUMN_PARENT_ID).Eq(parentID),
+					goqu.C(COLUMN_NAME).Eq(name),
+				),
+			),
+		).
+		Limit(1)
+
+	if store.lockingReadsSupported() {
+		// A locking read sees the rows committed by concurrent
+		// transactions, not the snapshot of the current one
+		query = query.ForUpdate(exp.Wait)
+	}
+
+	sqlStr, params, errSql := query.ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < 1 {
+		return nil, nil
+	}
+
+	return NewRecordFromExistingData(rows[0]), nil
+}
+
+// recordEnsureUnique checks that no other live record has the path, or the
+// parent ID and name, of the record. With autoSuffix the record is given
+// the first free suffixed name instead of failing. It must be called in
+// the transaction inserting or updating the record.
+func (store *Store) recordEnsureUnique(record *Record, autoSuffix bool) error {
+	if record.DeletedAt() != sb.NULL_DATETIME {
+		return nil // soft deleted records do not take the path
+	}
+
+	err := store.recordLock(record.ParentID())
+
+	if err != nil {
+		return err
+	}
+
+	conflict, err := store.recordConflict(record.ID(), record.ParentID(), record.Name(), record.Path())
+
+	if err != nil {
+		return err
+	}
+
+	if conflict == nil {
+		return nil
+	}
+
+	if !autoSuffix {
+		return wrapError(ErrExists, "record already exists at path: "+record.Path())
+	}
+
+	return suffixedNameSet(record, store.pathRules, func(name string, recordPath string) (bool, error) {
+		conflict, err := store.recordConflict(record.ID(), record.ParentID(), name, recordPath)
+		return conflict != nil, err
+	})
+}
+
+// recordUpdateEnsureUnique checks the uniqueness of the record with the ID,
+// as it will be once the changed data is saved
+func (store *Store) recordUpdateEnsureUnique(id string, dataChanged map[string]string) error {
+	stored, err := store.recordFindByID(id, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_PARENT_ID, COLUMN_NAME, COLUMN_PATH, COLUMN_DELETED_AT},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if stored == nil {
+		return nil // nothing is updated
+	}
+
+	data := stored.Data()
+
+	for key, value := range dataChanged {
+		data[key] = value
+	}
+
+	return store.recordEnsureUnique(NewRecordFromExistingData(data), false)
+}
+
+// recordLock locks the record with the ID until the end of the transaction,
+// on the databases supporting locking reads
+func (store *Store) recordLock(id string) error {
+	if !store.lockingReadsSupported() {
+		return nil
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.tableName).
+		Prepared(true).
+		Select(COLUMN_ID).
+		Where(goqu.C(COLUMN_ID).Eq(id)).
+		ForUpdate(exp.Wait).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err := store.selectToMapString(sqlStr, params...)
+
+	return err
+}
+
+// lockingReadsSupported returns whether SELECT ... FOR UPDATE is supported
+func (store *Store) lockingReadsSupported() bool {
+	return store.dbDriverName == sb.DIALECT_MYSQL || store.dbDriverName == sb.DIALECT_POSTGRES
+}
+
+// uniqueError returns ErrExists for the path if the error is a violation
+// of the unique index, the error itself otherwise
+func (store *Store) uniqueError(err error, recordPath string) error {
+	if err == nil {
+		return nil
+	}
+
+	message := strings.ToLower(err.Error())
+
+	if strings.Contains(message, "unique constraint") || // SQLite, PostgreSQL
+		strings.Contains(message, "duplicate entry") { // MySQL
+		return wrapError(ErrExists, "record already exists at path: "+recordPath)
+	}
+
+	return err
+}
+
+// suffixedNameSet gives the record the first suffixed name which is valid
+// by the rules and does not conflict, failing with ErrExists when none is
+// found within suffixAttemptsMax attempts
+func suffixedNameSet(record *Record, rules PathRules, conflicts func(name string, recordPath string) (bool, error)) error {
+	parentPath := path.Dir(record.Path())
+
+	for n := 1; n <= suffixAttemptsMax; n++ {
+		name := suffixedName(record.Name(), n)
+		recordPath := path.Join(parentPath, name)
+
+		// The suffix makes the name and path longer
+		err := rules.validateData(map[string]string{COLUMN_NAME: name, COLUMN_PATH: recordPath})
+
+		if err != nil {
+			return err
+		}
+
+		conflict, err := conflicts(name, recordPath)
+
+		if err != nil {
+			return err
+		}
+
+		if !conflict {
+			record.SetName(name)
+			record.SetPath(recordPath)
+			return nil
+		}
+	}
+
+	return wrapError(ErrExists, "no free suffixed name for the path: "+record.Path())
+}
+
+// suffixedName returns the name with the suffix (n) added before
+// the extension, e.g. "report.pdf" becomes "report (1).pdf". A suffix
+// the name already has is replaced, "report (1).pdf" does not become
+// "report (1) (1).pdf".
+func suffixedName(name string, n int) string {
+	extension := path.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+
+	if base == "" {
+		// A dot file, e.g. ".env", has no extension
+		base, extension = name, ""
+	}
+
+	if matches := suffixRegexp.FindStringSubmatch(base); matches != nil {
+		base = matches[1]
+	}
+
+	return base + " (" + strconv.Itoa(n) + ")" + extension
+}
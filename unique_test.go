@@ -0,0 +1,260 @@
+package sqlfilestore
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoreUnique(t *testing.T) {
+	db := initDB(":memory:")
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          "file_unique",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	testUnique(t, store)
+}
+
+func TestMemoryStoreUnique(t *testing.T) {
+	testUnique(t, NewMemoryStore())
+}
+
+func TestStoreUniqueIndex(t *testing.T) {
+	store := initMoveStore(t, "file_unique_index")
+
+	readme, err := store.RecordFindByPath("/docs/readme.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Inserted directly, bypassing the checks of RecordCreate
+	_, err = store.executeSql(`INSERT INTO "file_unique_index" ("id", "parent_id", "type", "name", "contents", "size", "extension", "path", "created_at", "updated_at", "deleted_at") VALUES (?, ?, ?, ?, '', '0', ?, ?, ?, ?, ?)`,
+		"duplicate", readme.ParentID(), readme.Type(), readme.Name(), readme.Extension(), "/docs/other.txt", readme.CreatedAt(), readme.UpdatedAt(), readme.DeletedAt())
+
+	if err == nil {
+		t.Fatal("The unique index MUST reject a second live record with the same parent and name")
+	}
+
+	if !errors.Is(store.uniqueError(err, readme.Path()), ErrExists) {
+		t.Fatal("expected the error to be mapped to ErrExists, found:", err)
+	}
+}
+
+func TestStoreUniqueConcurrentCreate(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "unique.db")+"?_pragma=busy_timeout(10000)")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	store, err := NewStore(NewStoreOptions{
+		DB:                 db,
+		TableName:          "file_unique_concurrent",
+		AutomigrateEnabled: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// The inserts which lose the race fail, with ErrExists
+			// or with a locking error, depending on the timing
+			_ = store.RecordCreate(newReportFile().
+				SetName("report.pdf").
+				SetPath("/report.pdf"))
+		}()
+	}
+
+	wg.Wait()
+
+	count, err := store.RecordCount(RecordQueryOptions{Path: "/report.pdf"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("There MUST be exactly one record at the path, found:", count)
+	}
+}
+
+func testUnique(t *testing.T, store StoreInterface) {
+	err := store.WriteFile("/report.pdf", []byte("REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Same path
+	err = store.RecordCreate(newReportFile().SetName("report.pdf").SetPath("/report.pdf"))
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	// Same parent and name, different path
+	err = store.RecordCreate(newReportFile().SetName("report.pdf").SetPath("/elsewhere/report.pdf"))
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	// An update taking the path of another record
+	err = store.WriteFile("/other.pdf", []byte("OTHER"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	other, err := store.RecordFindByPath("/other.pdf", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	other.SetName("report.pdf").SetPath("/report.pdf")
+
+	err = store.RecordUpdate(other)
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	// A soft deleted record does not take the path
+	err = store.Remove("/other.pdf")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	report, err := store.RecordFindByPath("/report.pdf", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(report)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/report.pdf", []byte("NEW REPORT"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Restoring the soft deleted record would make a duplicate
+	err = store.RecordRestore(report)
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists, found:", err)
+	}
+
+	// Auto suffix
+	store.EnableAutoSuffix(true)
+
+	for _, expected := range []string{"/report (1).pdf", "/report (2).pdf"} {
+		record := newReportFile().SetName("report.pdf").SetPath("/report.pdf")
+
+		err = store.RecordCreate(record)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if record.Path() != expected {
+			t.Fatal("expected path:", expected, "found:", record.Path())
+		}
+
+		if record.Name() != filepath.Base(expected) {
+			t.Fatal("expected name:", filepath.Base(expected), "found:", record.Name())
+		}
+	}
+
+	err = store.Copy("/report.pdf", "/report (1).pdf", CopyOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	data, err := store.ReadFile("/report (3).pdf")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(data) != "NEW REPORT" {
+		t.Fatal("unexpected contents:", string(data))
+	}
+
+	err = store.Rename("/report (3).pdf", "/report.pdf")
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("Rename MUST NOT add a suffix, expected ErrExists, found:", err)
+	}
+
+	// A suffixed name is validated, a name at the maximum length having
+	// no room for the suffix
+	store.SetPathRules(PathRules{MaxNameLength: len("long.pdf")})
+
+	err = store.RecordCreate(newReportFile().SetName("long.pdf").SetPath("/long.pdf"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordCreate(newReportFile().SetName("long.pdf").SetPath("/long.pdf"))
+
+	if !errors.Is(err, ErrNameTooLong) {
+		t.Fatal("expected ErrNameTooLong, found:", err)
+	}
+
+	store.SetPathRules(DefaultPathRules())
+
+	// The suffixes tried are limited
+	for n := 0; n <= suffixAttemptsMax; n++ {
+		err = store.RecordCreate(newReportFile().SetName("many.pdf").SetPath("/many.pdf"))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	err = store.RecordCreate(newReportFile().SetName("many.pdf").SetPath("/many.pdf"))
+
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("expected ErrExists once the suffixes are exhausted, found:", err)
+	}
+
+	if suffixedName(".env", 1) != ".env (1)" || suffixedName("docs", 2) != "docs (2)" {
+		t.Fatal("unexpected suffixed names:", suffixedName(".env", 1), suffixedName("docs", 2))
+	}
+}
+
+func newReportFile() *Record {
+	return NewFile().
+		SetParentID(ROOT_ID).
+		SetContents("").
+		SetSize("0").
+		SetExtension("pdf")
+}
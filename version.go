@@ -0,0 +1,764 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/dataobject"
+	"github.com/gouniverse/uid"
+	"github.com/samber/lo"
+)
+
+// == CLASS ==================================================================
+
+// Version is a version of the contents of a file. When versioning is
+// enabled, a version is kept each time the contents of a file are
+// written, the latest version having the current contents.
+type Version struct {
+	dataobject.DataObject
+}
+
+// VersionOptions are the details recorded with a version,
+// see RecordUpdateVersioned
+type VersionOptions struct {
+	// Author is who wrote the contents, optional
+	Author string
+
+	// Comment describes the change, optional
+	Comment string
+}
+
+// VersionRetention is the policy deciding which versions of a file are
+// kept, as versions are added and by VersionPurge. A version is kept if
+// it is one of the KeepLast latest versions, or if it was created less
+// than KeepNewerThan ago. The latest version, with the current contents,
+// is always kept. The zero value keeps all the versions.
+type VersionRetention struct {
+	// KeepLast is the number of latest versions kept
+	KeepLast int
+
+	// KeepNewerThan is the age under which the versions are kept
+	KeepNewerThan time.Duration
+}
+
+// versionMetadataColumns are the columns of a version, but the contents
+var versionMetadataColumns = []string{
+	COLUMN_ID,
+	COLUMN_RECORD_ID,
+	COLUMN_VERSION,
+	COLUMN_SIZE,
+	COLUMN_HASH,
+	COLUMN_CONTENT_ID,
+	COLUMN_AUTHOR,
+	COLUMN_COMMENT,
+	COLUMN_CREATED_AT,
+}
+
+// == CONSTRUCTORS ===========================================================
+
+func NewVersionFromExistingData(data map[string]string) *Version {
+	o := &Version{}
+	o.Hydrate(data)
+	return o
+}
+
+// == SETTERS AND GETTERS =====================================================
+
+func (o *Version) ID() string {
+	return o.Get(COLUMN_ID)
+}
+
+// RecordID returns the ID of the file the version is of
+func (o *Version) RecordID() string {
+	return o.Get(COLUMN_RECORD_ID)
+}
+
+// Number returns the number of the version, starting at 1 for each file
+func (o *Version) Number() int {
+	number, _ := strconv.Atoi(o.Get(COLUMN_VERSION))
+	return number
+}
+
+func (o *Version) Size() string {
+	return o.Get(COLUMN_SIZE)
+}
+
+// Hash returns the hex encoded SHA-256 of the contents of the version
+func (o *Version) Hash() string {
+	return o.Get(COLUMN_HASH)
+}
+
+// ContentID returns the ID of the row of the content table the contents
+// are kept in, empty if they are kept in the version
+func (o *Version) ContentID() string {
+	return o.Get(COLUMN_CONTENT_ID)
+}
+
+// Contents returns the contents of the version, returned by VersionGet only
+func (o *Version) Contents() string {
+	return o.Get(COLUMN_CONTENTS)
+}
+
+// ContentsBytes returns the contents as a byte slice,
+// to be used for binary files (images, PDFs, etc.)
+func (o *Version) ContentsBytes() []byte {
+	return []byte(o.Contents())
+}
+
+// contentsHydrate sets the contents loaded by the store, on a copy of
+// the data, without marking them as changed
+func (o *Version) contentsHydrate(contents string) {
+	data := copyData(o.Data())
+	data[COLUMN_CONTENTS] = contents
+	o.Hydrate(data)
+}
+
+func (o *Version) Author() string {
+	return o.Get(COLUMN_AUTHOR)
+}
+
+func (o *Version) Comment() string {
+	return o.Get(COLUMN_COMMENT)
+}
+
+func (o *Version) CreatedAt() string {
+	return o.Get(COLUMN_CREATED_AT)
+}
+
+// == PUBLIC METHODS =========================================================
+
+// EnableVersioning makes the store keep a version of a file each time
+// its contents are written
+func (store *Store) EnableVersioning(enabled bool) {
+	store.versioningEnabled = enabled
+}
+
+// EnableVersioning makes the store keep a version of a file each time
+// its contents are written
+func (store *MemoryStore) EnableVersioning(enabled bool) {
+	store.versioningEnabled = enabled
+}
+
+// SetVersionRetention sets the policy deciding which versions are kept
+func (store *Store) SetVersionRetention(retention VersionRetention) {
+	store.versionRetention = retention
+}
+
+// SetVersionRetention sets the policy deciding which versions are kept
+func (store *MemoryStore) SetVersionRetention(retention VersionRetention) {
+	store.versionRetention = retention
+}
+
+// RecordUpdateVersioned updates the record like RecordUpdate, recording
+// the author and comment of the options with the version kept for a
+// change of the contents, when versioning is enabled
+func (store *Store) RecordUpdateVersioned(record *Record, options VersionOptions) error {
+	return operations{store}.recordUpdateVersioned(record, options)
+}
+
+// RecordUpdateVersioned updates the record like RecordUpdate, recording
+// the author and comment of the options with the version kept for a
+// change of the contents, when versioning is enabled
+func (store *MemoryStore) RecordUpdateVersioned(record *Record, options VersionOptions) error {
+	return operations{store}.recordUpdateVersioned(record, options)
+}
+
+// VersionList returns the versions of the file with the ID, the latest
+// first, without their contents
+func (store *Store) VersionList(id string) ([]Version, error) {
+	return operations{store}.versionList(id)
+}
+
+// VersionList returns the versions of the file with the ID, the latest
+// first, without their contents
+func (store *MemoryStore) VersionList(id string) ([]Version, error) {
+	return operations{store}.versionList(id)
+}
+
+// VersionGet returns the version of the file with the ID with its number,
+// with its contents, or nil if there is none. In strict mode ErrNotFound
+// is returned instead of nil.
+func (store *Store) VersionGet(id string, number int) (*Version, error) {
+	return operations{store}.versionGet(id, number)
+}
+
+// VersionGet returns the version of the file with the ID with its number,
+// with its contents, or nil if there is none. In strict mode ErrNotFound
+// is returned instead of nil.
+func (store *MemoryStore) VersionGet(id string, number int) (*Version, error) {
+	return operations{store}.versionGet(id, number)
+}
+
+// VersionRestore makes the contents of the version of the file with the
+// ID with its number the current contents of the file. The restored
+// contents are kept as the latest version, when versioning is enabled.
+func (store *Store) VersionRestore(id string, number int) error {
+	return operations{store}.versionRestore(id, number)
+}
+
+// VersionRestore makes the contents of the version of the file with the
+// ID with its number the current contents of the file. The restored
+// contents are kept as the latest version, when versioning is enabled.
+func (store *MemoryStore) VersionRestore(id string, number int) error {
+	return operations{store}.versionRestore(id, number)
+}
+
+// VersionPurge deletes the versions of all the files which the retention
+// policy does not keep, returning the number of versions deleted. The
+// versions of each file are deleted in their own transaction.
+func (store *Store) VersionPurge() (int, error) {
+	return operations{store}.versionPurge()
+}
+
+// VersionPurge deletes the versions of all the files which the retention
+// policy does not keep, returning the number of versions deleted
+func (store *MemoryStore) VersionPurge() (int, error) {
+	return operations{store}.versionPurge()
+}
+
+// == PRIVATE METHODS ========================================================
+
+// recordUpdateVersioned implements RecordUpdateVersioned
+func (store operations) recordUpdateVersioned(record *Record, options VersionOptions) error {
+	if !store.settings().versioningEnabled {
+		return store.recordUpdate(record, "", nil)
+	}
+
+	return store.recordUpdate(record, "", &options)
+}
+
+// versionList implements VersionList
+func (store operations) versionList(id string) ([]Version, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+
+	// The versionList of the store, which this method shadows
+	return store.recordStorage.versionList(id)
+}
+
+// versionGet implements VersionGet
+func (store operations) versionGet(id string, number int) (*Version, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+
+	version, err := store.versionFind(id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if version == nil {
+		if store.settings().strictModeEnabled {
+			return nil, wrapError(ErrNotFound, "version not found: "+strconv.Itoa(number))
+		}
+
+		return nil, nil
+	}
+
+	err = store.versionContentsLoad(version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// versionRestore implements VersionRestore
+func (store operations) versionRestore(id string, number int) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+
+	return store.transaction(func(txStore operations) error {
+		record, err := txStore.recordFindByID(id, RecordQueryOptions{Columns: fsMetadataColumns})
+
+		if err != nil {
+			return err
+		}
+
+		if record == nil {
+			return wrapError(ErrNotFound, "record not found: "+id)
+		}
+
+		if record.IsDirectory() {
+			return errors.New("is a directory")
+		}
+
+		version, err := txStore.versionFind(id, number)
+
+		if err != nil {
+			return err
+		}
+
+		if version == nil {
+			return wrapError(ErrNotFound, "version not found: "+strconv.Itoa(number))
+		}
+
+		record.SetContents(version.Contents()).SetSize(version.Size())
+
+		// The hash of streamed contents is that of their chunks
+		err = txStore.recordUpdate(record, version.Hash(), nil)
+
+		if err != nil {
+			return err
+		}
+
+		// The streamed contents of the version, if any
+		err = txStore.chunksCopy(version.ID(), record.ID())
+
+		if err != nil || !txStore.settings().versioningEnabled {
+			return err
+		}
+
+		return txStore.versionCreate(record.ID(), VersionOptions{
+			Comment: "restored version " + strconv.Itoa(number),
+		})
+	})
+}
+
+// versionPurge implements VersionPurge
+func (store operations) versionPurge() (int, error) {
+	if store.settings().versionRetention.keepsAll() {
+		return 0, nil
+	}
+
+	recordIDs, err := store.versionRecordIDs()
+
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, recordID := range recordIDs {
+		deleted := 0
+
+		err = store.transaction(func(txStore operations) error {
+			var err error
+			deleted, err = txStore.versionsPrune(recordID)
+			return err
+		})
+
+		if err != nil {
+			return purged, err
+		}
+
+		purged += deleted
+	}
+
+	return purged, nil
+}
+
+// versionRecordIDs returns the IDs of the records which have versions
+func (store *Store) versionRecordIDs() ([]string, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.versionTableName).
+		Prepared(true).
+		SelectDistinct(goqu.C(COLUMN_RECORD_ID)).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) string {
+		return row[COLUMN_RECORD_ID]
+	}), nil
+}
+
+// versionCreate keeps the current contents of the record with the ID as
+// its next version, and deletes the versions the retention does not keep
+func (store *Store) versionCreate(recordID string, options VersionOptions) error {
+	record, err := store.recordFindByID(recordID, RecordQueryOptions{
+		Columns:         []string{COLUMN_ID, COLUMN_CONTENT_ID},
+		WithSoftDeleted: true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return wrapError(ErrNotFound, "record not found: "+recordID)
+	}
+
+	last, err := store.versionLast(recordID)
+
+	if err != nil {
+		return err
+	}
+
+	versionID := uid.HumanUid()
+
+	// The contents are copied by the database, without being loaded in memory
+	selectQuery := goqu.From(store.tableName).
+		Prepared(true).
+		Select(
+			goqu.V(versionID),
+			goqu.V(recordID),
+			goqu.V(last+1),
+			goqu.C(COLUMN_SIZE),
+			goqu.C(COLUMN_HASH),
+			goqu.C(COLUMN_CONTENTS),
+			goqu.C(COLUMN_CODEC),
+			goqu.C(COLUMN_CONTENT_ID),
+			goqu.V(options.Author),
+			goqu.V(options.Comment),
+			goqu.V(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)),
+		).
+		Where(goqu.C(COLUMN_ID).Eq(recordID))
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Insert(store.versionTableName).
+		Prepared(true).
+		Cols(
+			COLUMN_ID,
+			COLUMN_RECORD_ID,
+			COLUMN_VERSION,
+			COLUMN_SIZE,
+			COLUMN_HASH,
+			COLUMN_CONTENTS,
+			COLUMN_CODEC,
+			COLUMN_CONTENT_ID,
+			COLUMN_AUTHOR,
+			COLUMN_COMMENT,
+			COLUMN_CREATED_AT,
+		).
+		FromQuery(selectQuery).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	// The version shares the content row of the record
+	if record.ContentID() != "" {
+		err = store.contentReferencesAdd(record.ContentID())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = store.chunksCopy(recordID, versionID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = store.versionsPrune(recordID)
+
+	return err
+}
+
+// versionLast returns the number of the latest version of the record
+// with the ID, 0 if it has none
+func (store *Store) versionLast(recordID string) (int, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.versionTableName).
+		Prepared(true).
+		Select(goqu.MAX(goqu.C(COLUMN_VERSION)).As(COLUMN_VERSION)).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(recordID)).
+		ToSQL()
+
+	if errSql != nil {
+		return 0, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rows) == 0 || rows[0][COLUMN_VERSION] == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(rows[0][COLUMN_VERSION])
+}
+
+// versionList returns the versions of the record with the ID, the latest
+// first, without their contents
+func (store *Store) versionList(recordID string) ([]Version, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.versionTableName).
+		Prepared(true).
+		Select(lo.Map(versionMetadataColumns, func(column string, _ int) any {
+			return goqu.C(column)
+		})...).
+		Where(goqu.C(COLUMN_RECORD_ID).Eq(recordID)).
+		Order(goqu.C(COLUMN_VERSION).Desc()).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) Version {
+		return *NewVersionFromExistingData(row)
+	}), nil
+}
+
+// versionFind returns the version of the record with the ID with its
+// number, with its contents unless they were streamed, or nil
+func (store *Store) versionFind(recordID string, number int) (*Version, error) {
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		From(store.versionTableName).
+		Prepared(true).
+		Where(
+			goqu.C(COLUMN_RECORD_ID).Eq(recordID),
+			goqu.C(COLUMN_VERSION).Eq(number),
+		).
+		Limit(1).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// The contents are loaded and decompressed as those of a record
+	records := []Record{*NewRecordFromExistingData(rows[0])}
+
+	err = store.contentsLoad(records)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVersionFromExistingData(records[0].Data()), nil
+}
+
+// versionContentsLoad loads the streamed contents of the version,
+// which are not returned by versionFind
+func (store *Store) versionContentsLoad(version *Version) error {
+	chunks, err := store.chunkList(version.ID())
+
+	if err != nil || len(chunks) == 0 {
+		return err
+	}
+
+	contents, err := io.ReadAll(newChunkReader(store, version.ID(), chunks))
+
+	if err != nil {
+		return err
+	}
+
+	version.contentsHydrate(string(contents))
+
+	return nil
+}
+
+// versionsPrune deletes the versions of the record with the ID which the
+// retention does not keep, returning the number of versions deleted
+func (store *Store) versionsPrune(recordID string) (int, error) {
+	if store.versionRetention.keepsAll() {
+		return 0, nil
+	}
+
+	versions, err := store.versionList(recordID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	expired := versionsExpired(versions, store.versionRetention)
+
+	for _, version := range expired {
+		err = store.versionDelete(version)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+// versionDelete deletes the version, with its contents
+func (store *Store) versionDelete(version Version) error {
+	if version.ContentID() != "" {
+		err := store.contentReferencesRemove(version.ContentID(), 1)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := store.chunksDelete(version.ID())
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Delete(store.versionTableName).
+		Prepared(true).
+		Where(goqu.C(COLUMN_ID).Eq(version.ID())).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// versionsDelete deletes the versions of the records matching the
+// condition, with their contents
+func (store *Store) versionsDelete(where exp.Expression) error {
+	recordIDs := goqu.Dialect(store.dbDriverName).
+		From(store.tableName).
+		Select(goqu.C(COLUMN_ID)).
+		Where(where)
+
+	versionWhere := goqu.C(COLUMN_RECORD_ID).In(recordIDs)
+
+	err := store.contentsRelease(store.versionTableName, versionWhere)
+
+	if err != nil {
+		return err
+	}
+
+	versionIDs := goqu.Dialect(store.dbDriverName).
+		From(store.versionTableName).
+		Select(goqu.C(COLUMN_ID)).
+		Where(versionWhere)
+
+	sqlStr, params, errSql := goqu.Dialect(store.dbDriverName).
+		Delete(store.chunkTableName).
+		Prepared(true).
+		Where(goqu.C(COLUMN_RECORD_ID).In(versionIDs)).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, params, errSql = goqu.Dialect(store.dbDriverName).
+		Delete(store.versionTableName).
+		Prepared(true).
+		Where(versionWhere).
+		ToSQL()
+
+	if errSql != nil {
+		return errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = store.executeSql(sqlStr, params...)
+
+	return err
+}
+
+// keepsAll returns whether the retention keeps all the versions
+func (retention VersionRetention) keepsAll() bool {
+	return retention.KeepLast <= 0 && retention.KeepNewerThan <= 0
+}
+
+// versionsExpired returns the versions, sorted the latest first, which
+// the retention does not keep
+func versionsExpired(versions []Version, retention VersionRetention) []Version {
+	if retention.keepsAll() {
+		return []Version{}
+	}
+
+	createdAfter := carbon.CreateFromStdTime(time.Now().Add(-retention.KeepNewerThan)).ToDateTimeString(carbon.UTC)
+
+	expired := []Version{}
+
+	for i, version := range versions {
+		// The latest version has the current contents
+		if i == 0 {
+			continue
+		}
+
+		if retention.KeepLast > 0 && i < retention.KeepLast {
+			continue
+		}
+
+		if retention.KeepNewerThan > 0 && version.CreatedAt() > createdAfter {
+			continue
+		}
+
+		expired = append(expired, version)
+	}
+
+	return expired
+}
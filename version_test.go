@@ -0,0 +1,352 @@
+package sqlfilestore
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestStoreVersions(t *testing.T) {
+	testVersions(t, initTxStore(t, "file_versions"))
+}
+
+func TestMemoryStoreVersions(t *testing.T) {
+	testVersions(t, NewMemoryStore())
+}
+
+func testVersions(t *testing.T, store StoreInterface) {
+	store.EnableVersioning(true)
+
+	versionNumbers := func(id string) []int {
+		versions, err := store.VersionList(id)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		numbers := []int{}
+
+		for _, version := range versions {
+			numbers = append(numbers, version.Number())
+		}
+
+		return numbers
+	}
+
+	for _, contents := range []string{"v1", "v2"} {
+		err := store.WriteFile("/doc.txt", []byte(contents))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	record, err := store.RecordFindByPath("/doc.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record.SetContents("v3").SetSize("2")
+
+	err = store.RecordUpdateVersioned(record, VersionOptions{Author: "ann", Comment: "third"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Not a change of the contents
+	err = store.Rename("/doc.txt", "/renamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if numbers := versionNumbers(record.ID()); len(numbers) != 3 || numbers[0] != 3 || numbers[2] != 1 {
+		t.Fatal("A version MUST be kept for each write of the contents, the latest first:", numbers)
+	}
+
+	versions, err := store.VersionList(record.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if versions[0].Author() != "ann" || versions[0].Comment() != "third" || versions[0].Size() != "2" {
+		t.Fatal("The version MUST record the options:", versions[0].Data())
+	}
+
+	if versions[2].Hash() != contentsHash("v1") || versions[2].CreatedAt() == "" {
+		t.Fatal("The version MUST record the hash of the contents:", versions[2].Data())
+	}
+
+	if _, loaded := versions[0].Data()[COLUMN_CONTENTS]; loaded {
+		t.Fatal("The versions MUST be listed without their contents")
+	}
+
+	version, err := store.VersionGet(record.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if version == nil || version.Contents() != "v1" {
+		t.Fatal("The version MUST have its contents:", version)
+	}
+
+	missing, err := store.VersionGet(record.ID(), 9)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if missing != nil {
+		t.Fatal("A missing version MUST be nil:", missing.Data())
+	}
+
+	err = store.VersionRestore(record.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents, err := store.ReadFile("/renamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if string(contents) != "v1" {
+		t.Fatal("The contents of the version MUST be restored, found:", string(contents))
+	}
+
+	restored, err := store.VersionGet(record.ID(), 4)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if restored == nil || restored.Contents() != "v1" || restored.Comment() != "restored version 1" {
+		t.Fatal("The restored contents MUST be kept as the latest version:", restored)
+	}
+
+	err = store.VersionRestore(record.ID(), 9)
+
+	if err == nil {
+		t.Fatal("Restoring a missing version MUST fail")
+	}
+
+	// Streamed contents
+	streamed := bytes.Repeat([]byte("STREAMED"), 10)
+
+	writer, err := store.CreateWriter("/streamed.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write(streamed)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	streamedRecord, err := store.RecordFindByPath("/streamed.bin", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	streamedVersion, err := store.VersionGet(streamedRecord.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if streamedVersion == nil || !bytes.Equal(streamedVersion.ContentsBytes(), streamed) {
+		t.Fatal("The version MUST have the streamed contents:", streamedVersion)
+	}
+
+	// A new file without contents has no version
+	empty, err := store.OpenFile("/empty.txt", os.O_CREATE)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if numbers := versionNumbers(empty.ID()); len(numbers) != 0 {
+		t.Fatal("A new empty file MUST have no version:", numbers)
+	}
+
+	// Retention
+	store.SetVersionRetention(VersionRetention{KeepLast: 2})
+
+	err = store.WriteFile("/renamed.txt", []byte("v5"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if numbers := versionNumbers(record.ID()); len(numbers) != 2 || numbers[0] != 5 || numbers[1] != 4 {
+		t.Fatal("The versions MUST be pruned by the retention:", numbers)
+	}
+
+	store.SetVersionRetention(VersionRetention{KeepLast: 1})
+
+	purged, err := store.VersionPurge()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if purged != 1 {
+		t.Fatal("expected 1 version purged, found:", purged)
+	}
+
+	if numbers := versionNumbers(record.ID()); len(numbers) != 1 || numbers[0] != 5 {
+		t.Fatal("The latest version MUST be kept:", numbers)
+	}
+
+	err = store.Remove("/renamed.txt")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if numbers := versionNumbers(record.ID()); len(numbers) != 0 {
+		t.Fatal("The versions MUST be deleted with the file:", numbers)
+	}
+}
+
+func TestVersionsExpired(t *testing.T) {
+	versions := []Version{
+		*NewVersionFromExistingData(map[string]string{COLUMN_VERSION: "4", COLUMN_CREATED_AT: "2000-01-04 00:00:00"}),
+		*NewVersionFromExistingData(map[string]string{COLUMN_VERSION: "3", COLUMN_CREATED_AT: "2000-01-03 00:00:00"}),
+		*NewVersionFromExistingData(map[string]string{COLUMN_VERSION: "2", COLUMN_CREATED_AT: "2000-01-02 00:00:00"}),
+		*NewVersionFromExistingData(map[string]string{COLUMN_VERSION: "1", COLUMN_CREATED_AT: "2000-01-01 00:00:00"}),
+	}
+
+	numbers := func(versions []Version) []int {
+		numbers := []int{}
+
+		for _, version := range versions {
+			numbers = append(numbers, version.Number())
+		}
+
+		return numbers
+	}
+
+	if expired := versionsExpired(versions, VersionRetention{}); len(expired) != 0 {
+		t.Fatal("The zero retention MUST keep all the versions:", numbers(expired))
+	}
+
+	if expired := numbers(versionsExpired(versions, VersionRetention{KeepLast: 2})); len(expired) != 2 || expired[0] != 2 || expired[1] != 1 {
+		t.Fatal("The last versions MUST be kept:", expired)
+	}
+
+	// Older than any duration, but the latest
+	if expired := numbers(versionsExpired(versions, VersionRetention{KeepNewerThan: 1})); len(expired) != 3 {
+		t.Fatal("The latest version MUST be kept:", expired)
+	}
+
+	// Newer than any of them
+	if expired := versionsExpired(versions, VersionRetention{KeepLast: 1, KeepNewerThan: 1 << 62}); len(expired) != 0 {
+		t.Fatal("The versions newer than the duration MUST be kept:", numbers(expired))
+	}
+}
+
+func TestStoreVersionsContentTable(t *testing.T) {
+	store := initStoreWithOptions(t, NewStoreOptions{
+		TableName:             "file_versions_content",
+		BinaryContentsEnabled: true,
+		ContentTableEnabled:   true,
+	})
+	store.EnableVersioning(true)
+
+	for _, contents := range []string{"LOGO", "LOGO", "OTHER"} {
+		err := store.WriteFile("/logo.png", []byte(contents))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	// LOGO is shared by two versions, OTHER by the record and its latest version
+	if tableCount(t, store, store.contentTableName, "1 = 1") != 2 {
+		t.Fatal("The versions MUST share the content rows")
+	}
+
+	if tableCount(t, store, store.contentTableName, "ref_count = 2") != 2 {
+		t.Fatal("The versions MUST reference the content rows")
+	}
+
+	writer, err := store.CreateWriter("/streamed.bin")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = writer.Write([]byte("STREAMED"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	record, err := store.RecordFindByPath("/logo.png", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	version, err := store.VersionGet(record.ID(), 1)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if version == nil || version.Contents() != "LOGO" {
+		t.Fatal("The version MUST have the contents of the content table:", version)
+	}
+
+	store.SetVersionRetention(VersionRetention{KeepLast: 1})
+
+	purged, err := store.VersionPurge()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if purged != 2 {
+		t.Fatal("expected 2 versions purged, found:", purged)
+	}
+
+	if tableCount(t, store, store.contentTableName, "1 = 1") != 1 {
+		t.Fatal("The contents no longer referenced by a version MUST be deleted")
+	}
+
+	for _, filePath := range []string{"/logo.png", "/streamed.bin"} {
+		err = store.Remove(filePath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, tableName := range []string{store.contentTableName, store.versionTableName, store.chunkTableName} {
+		if count := tableCount(t, store, tableName, "1 = 1"); count != 0 {
+			t.Fatal("The rows of "+tableName+" MUST be deleted with the files, found:", count)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/samber/lo"
+)
+
+// WalkFunc is the type of the function called by Walk for each file and
+// directory, with the same semantics as fs.WalkDirFunc. The record has
+// no contents (use ReadFile or Open to read them).
+//
+// The function is first called with the root. If the root does not exist,
+// or the subtree cannot be loaded, the function is called with the error
+// instead, and a nil record when the root itself is missing. Returning
+// fs.SkipDir skips the directory (or, for a file, the remaining entries of
+// its directory), returning fs.SkipAll stops the walk, and returning any
+// other error stops the walk with the error.
+type WalkFunc func(filePath string, record *Record, err error) error
+
+// ListOptions define the options for listing a subtree with ListRecursive
+type ListOptions struct {
+	// MaxDepth limits the depth of the records listed, 1 being the
+	// children of the root. Zero lists the whole subtree.
+	MaxDepth int
+
+	// FilesOnly lists the files only
+	FilesOnly bool
+
+	// DirsOnly lists the directories only
+	DirsOnly bool
+}
+
+// Walk walks the tree rooted at root, calling fn for each file and
+// directory in the tree, including root, like fs.WalkDir. The entries are
+// walked in lexical order, each directory before its contents.
+//
+// The whole subtree is loaded with a single query (without the contents),
+// so that walking a large tree does not issue a query per directory.
+// Soft deleted records are not walked.
+func (store *Store) Walk(root string, fn WalkFunc) error {
+	return operations{store}.walk(root, fn)
+}
+
+// Walk walks the tree rooted at root, calling fn for each file and
+// directory in the tree, including root, like fs.WalkDir. The entries
+// are walked in lexical order, each directory before its contents.
+func (store *MemoryStore) Walk(root string, fn WalkFunc) error {
+	return operations{store}.walk(root, fn)
+}
+
+// ListRecursive returns the files and directories in the tree rooted at
+// root, the root excluded, in the order they are walked by Walk. The
+// records have no contents. The subtree is fetched with a single query,
+// the depth and type filters being applied by the database.
+func (store *Store) ListRecursive(root string, options ListOptions) ([]Record, error) {
+	return operations{store}.listRecursive(root, options)
+}
+
+// ListRecursive returns the files and directories in the tree rooted
+// at root, the root excluded, in the order they are walked by Walk
+func (store *MemoryStore) ListRecursive(root string, options ListOptions) ([]Record, error) {
+	return operations{store}.listRecursive(root, options)
+}
+
+// == PRIVATE METHODS ========================================================
+
+// walk implements Walk
+func (store operations) walk(root string, fn WalkFunc) error {
+	rootPath, errPath := store.cleanPath("walk", root)
+
+	if errPath != nil {
+		return walkResult(fn(root, nil, errPath))
+	}
+
+	record, err := store.recordFindByPath(rootPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err == nil && record == nil {
+		err = &fs.PathError{Op: "walk", Path: rootPath, Err: ErrNotFound}
+	}
+
+	if err != nil {
+		return walkResult(fn(rootPath, nil, err))
+	}
+
+	descendants := []Record{}
+
+	if record.IsDirectory() {
+		descendants, err = store.descendantsReachable(record, ListOptions{})
+
+		if err != nil {
+			return walkResult(fn(rootPath, record, err))
+		}
+	}
+
+	return walkResult(walkRecords(record, descendants, fn))
+}
+
+// listRecursive implements ListRecursive
+func (store operations) listRecursive(root string, options ListOptions) ([]Record, error) {
+	if options.FilesOnly && options.DirsOnly {
+		return nil, errors.New("files only and dirs only are mutually exclusive")
+	}
+
+	rootPath, errPath := store.cleanPath("list", root)
+
+	if errPath != nil {
+		return nil, errPath
+	}
+
+	record, err := store.recordFindByPath(rootPath, RecordQueryOptions{Columns: fsMetadataColumns})
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "list", Path: rootPath, Err: err}
+	}
+
+	if record == nil {
+		return nil, &fs.PathError{Op: "list", Path: rootPath, Err: ErrNotFound}
+	}
+
+	if !record.IsDirectory() {
+		return nil, &fs.PathError{Op: "list", Path: rootPath, Err: ErrNotDirectory}
+	}
+
+	descendants, err := store.descendantsReachable(record, options)
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "list", Path: rootPath, Err: err}
+	}
+
+	sortRecords(descendants)
+
+	return descendants, nil
+}
+
+// descendantsReachable returns the live descendants of the directory
+// matching the options, leaving out those under a soft deleted directory,
+// as RecordSoftDelete soft deletes a directory without its contents
+func (store operations) descendantsReachable(directory *Record, options ListOptions) ([]Record, error) {
+	descendants, err := store.descendantsList(directory, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deletedDirectories, err := store.RecordList(RecordQueryOptions{
+		Type:            TYPE_DIRECTORY,
+		PathStartsWith:  strings.TrimSuffix(directory.Path(), PATH_SEPARATOR) + PATH_SEPARATOR,
+		OnlySoftDeleted: true,
+		Columns:         []string{COLUMN_PATH},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deletedDirectories) == 0 {
+		return descendants, nil
+	}
+
+	return lo.Filter(descendants, func(descendant Record, _ int) bool {
+		return !lo.SomeBy(deletedDirectories, func(deleted Record) bool {
+			return strings.HasPrefix(descendant.Path(), deleted.Path()+PATH_SEPARATOR)
+		})
+	}), nil
+}
+
+// descendantsList returns the live descendants of the directory matching
+// the options, in no particular order
+func (store *Store) descendantsList(directory *Record, options ListOptions) ([]Record, error) {
+	where := []exp.Expression{
+		goqu.C(COLUMN_ID).Neq(directory.ID()),
+		subtreeWhere(directory.Path()),
+	}
+
+	if options.MaxDepth > 0 {
+		// The depth is the number of separators in the path
+		depth := goqu.L("LENGTH(?) - LENGTH(REPLACE(?, ?, ''))", goqu.C(COLUMN_PATH), goqu.C(COLUMN_PATH), PATH_SEPARATOR)
+		where = append(where, depth.Lte(pathDepth(directory.Path())+options.MaxDepth))
+	}
+
+	if options.FilesOnly {
+		where = append(where, goqu.C(COLUMN_TYPE).Eq(TYPE_FILE))
+	}
+
+	if options.DirsOnly {
+		where = append(where, goqu.C(COLUMN_TYPE).Eq(TYPE_DIRECTORY))
+	}
+
+	columns := lo.Map(fsMetadataColumns, func(column string, _ int) any {
+		return goqu.C(column)
+	})
+
+	sqlStr, params, errSql := store.recordQuery(RecordQueryOptions{}).
+		Prepared(true).
+		Select(columns...).
+		Where(where...).
+		ToSQL()
+
+	if errSql != nil {
+		return nil, errSql
+	}
+
+	if store.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := store.selectToMapString(sqlStr, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) Record {
+		return *NewRecordFromExistingData(row)
+	}), nil
+}
+
+// walkRecords calls fn for the root and its descendants, in the order of
+// Walk, handling fs.SkipDir as fs.WalkDir does
+func walkRecords(root *Record, descendants []Record, fn WalkFunc) error {
+	err := fn(root.Path(), root, nil)
+
+	if err != nil || !root.IsDirectory() {
+		return err
+	}
+
+	sortRecords(descendants)
+
+	skipPrefix := ""
+
+	for i := range descendants {
+		record := &descendants[i]
+
+		if skipPrefix != "" && strings.HasPrefix(record.Path(), skipPrefix) {
+			continue
+		}
+
+		skipPrefix = ""
+
+		err = fn(record.Path(), record, nil)
+
+		if err == nil {
+			continue
+		}
+
+		if err != fs.SkipDir {
+			return err
+		}
+
+		if record.IsDirectory() {
+			skipPrefix = record.Path() + PATH_SEPARATOR
+			continue
+		}
+
+		// A file skips the remaining entries of its directory
+		parentPath := path.Dir(record.Path())
+
+		if parentPath == root.Path() {
+			return nil
+		}
+
+		skipPrefix = parentPath + PATH_SEPARATOR
+	}
+
+	return nil
+}
+
+// walkResult returns the result of the walk, fs.SkipDir and fs.SkipAll
+// stopping the walk without an error
+func walkResult(err error) error {
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// sortRecords sorts the records in the order of a depth-first walk, each
+// directory before its contents, the names being in lexical order
+func sortRecords(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return pathLess(records[i].Path(), records[j].Path())
+	})
+}
+
+// pathLess compares the paths name by name, so that a directory sorts
+// before its contents and "/a/z" sorts before "/a b"
+func pathLess(a string, b string) bool {
+	namesA := strings.Split(strings.Trim(a, PATH_SEPARATOR), PATH_SEPARATOR)
+	namesB := strings.Split(strings.Trim(b, PATH_SEPARATOR), PATH_SEPARATOR)
+
+	for i := 0; i < len(namesA) && i < len(namesB); i++ {
+		if namesA[i] != namesB[i] {
+			return namesA[i] < namesB[i]
+		}
+	}
+
+	return len(namesA) < len(namesB)
+}
+
+// pathDepth returns the number of names in the (clean) path, 0 for the root
+func pathDepth(filePath string) int {
+	if filePath == ROOT_PATH {
+		return 0
+	}
+
+	return strings.Count(filePath, PATH_SEPARATOR)
+}
@@ -0,0 +1,203 @@
+package sqlfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestStoreWalk(t *testing.T) {
+	testWalk(t, initTxStore(t, "file_walk"))
+}
+
+func TestMemoryStoreWalk(t *testing.T) {
+	testWalk(t, NewMemoryStore())
+}
+
+func TestPathLess(t *testing.T) {
+	for _, paths := range [][2]string{
+		{"/", "/a"},
+		{"/a", "/a/z"},
+		{"/a/z", "/a b"},
+		{"/a b", "/b"},
+	} {
+		if !pathLess(paths[0], paths[1]) || pathLess(paths[1], paths[0]) {
+			t.Fatal("expected", paths[0], "before", paths[1])
+		}
+	}
+}
+
+func testWalk(t *testing.T, store StoreInterface) {
+	for _, dirPath := range []string{"/docs/b", "/docs z"} {
+		err := store.MkdirAll(dirPath)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	for _, filePath := range []string{"/docs/e.txt", "/docs/b/d.txt", "/docs/b/c.txt", "/docs/a.txt", "/other.txt", "/docs/deleted.txt"} {
+		err := store.WriteFile(filePath, []byte(filePath))
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	deleted, err := store.RecordFindByPath("/docs/deleted.txt", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(deleted)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The contents of a directory soft deleted on its own are not walked
+	err = store.MkdirAll("/docs/hidden/inner")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.WriteFile("/docs/hidden/inner/f.txt", []byte("hidden"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	hidden, err := store.RecordFindByPath("/docs/hidden", RecordQueryOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.RecordSoftDelete(hidden)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	walk := func(root string, skip map[string]error) []string {
+		paths := []string{}
+
+		err := store.Walk(root, func(filePath string, record *Record, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if record == nil || record.Path() != filePath || record.Contents() != "" {
+				t.Fatal("unexpected record for:", filePath, record)
+			}
+
+			paths = append(paths, filePath)
+
+			return skip[filePath]
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		return paths
+	}
+
+	for _, test := range []struct {
+		root     string
+		skip     map[string]error
+		expected string
+	}{
+		{"/docs", nil, "/docs /docs/a.txt /docs/b /docs/b/c.txt /docs/b/d.txt /docs/e.txt"},
+		{"/", nil, "/ /docs /docs/a.txt /docs/b /docs/b/c.txt /docs/b/d.txt /docs/e.txt /docs z /other.txt"},
+		{"/docs/a.txt", nil, "/docs/a.txt"},
+		{"/docs", map[string]error{"/docs/b": fs.SkipDir}, "/docs /docs/a.txt /docs/b /docs/e.txt"},
+		{"/docs", map[string]error{"/docs/b/c.txt": fs.SkipDir}, "/docs /docs/a.txt /docs/b /docs/b/c.txt /docs/e.txt"},
+		{"/docs", map[string]error{"/docs/a.txt": fs.SkipDir}, "/docs /docs/a.txt"},
+		{"/docs", map[string]error{"/docs/b": fs.SkipAll}, "/docs /docs/a.txt /docs/b"},
+		{"/", map[string]error{"/": fs.SkipDir}, "/"},
+	} {
+		paths := strings.Join(walk(test.root, test.skip), " ")
+
+		if paths != test.expected {
+			t.Fatal("expected:", test.expected, "found:", paths)
+		}
+	}
+
+	stop := errors.New("stop")
+
+	err = store.Walk("/docs", func(filePath string, record *Record, err error) error {
+		if filePath == "/docs/b" {
+			return stop
+		}
+
+		return err
+	})
+
+	if err != stop {
+		t.Fatal("expected the error of the function, found:", err)
+	}
+
+	err = store.Walk("/missing", func(filePath string, record *Record, err error) error {
+		if record != nil || !errors.Is(err, ErrNotFound) {
+			t.Fatal("expected ErrNotFound and a nil record, found:", err, record)
+		}
+
+		return err
+	})
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, found:", err)
+	}
+
+	list := func(root string, options ListOptions) string {
+		records, err := store.ListRecursive(root, options)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		paths := []string{}
+
+		for _, record := range records {
+			paths = append(paths, record.Path())
+		}
+
+		return strings.Join(paths, " ")
+	}
+
+	for _, test := range []struct {
+		root     string
+		options  ListOptions
+		expected string
+	}{
+		{"/docs", ListOptions{}, "/docs/a.txt /docs/b /docs/b/c.txt /docs/b/d.txt /docs/e.txt"},
+		{"/docs", ListOptions{MaxDepth: 1}, "/docs/a.txt /docs/b /docs/e.txt"},
+		{"/", ListOptions{MaxDepth: 1}, "/docs /docs z /other.txt"},
+		{"/docs", ListOptions{FilesOnly: true}, "/docs/a.txt /docs/b/c.txt /docs/b/d.txt /docs/e.txt"},
+		{"/", ListOptions{DirsOnly: true}, "/docs /docs/b /docs z"},
+		{"/", ListOptions{DirsOnly: true, MaxDepth: 1}, "/docs /docs z"},
+		{"/docs/b", ListOptions{}, "/docs/b/c.txt /docs/b/d.txt"},
+	} {
+		paths := list(test.root, test.options)
+
+		if paths != test.expected {
+			t.Fatal("expected:", test.expected, "found:", paths)
+		}
+	}
+
+	_, err = store.ListRecursive("/docs/a.txt", ListOptions{})
+
+	if !errors.Is(err, ErrNotDirectory) {
+		t.Fatal("expected ErrNotDirectory, found:", err)
+	}
+
+	_, err = store.ListRecursive("/docs", ListOptions{FilesOnly: true, DirsOnly: true})
+
+	if err == nil {
+		t.Fatal("FilesOnly and DirsOnly MUST be mutually exclusive")
+	}
+}